@@ -0,0 +1,131 @@
+package main
+
+import (
+	"math/rand/v2"
+	"sync"
+)
+
+// AllocationStrategy picks the slot given to a vehicle at check in.
+// free holds the free slots of the vehicle type sorted by floor & slot, it is never empty.
+// For large vehicles free only holds the first slot of every long enough run of adjacent free slots
+type AllocationStrategy interface {
+	Select(vtype VehicleType, free []*Slot) *Slot
+}
+
+// LowestSlotFirst -> lowest floor, lowest slot (the default)
+type LowestSlotFirst struct{}
+
+func (LowestSlotFirst) Select(vtype VehicleType, free []*Slot) *Slot {
+	return free[0]
+}
+
+// NearestToGate -> smallest Slot.Distance from the entry gate, ties go to the lowest slot
+type NearestToGate struct{}
+
+func (NearestToGate) Select(vtype VehicleType, free []*Slot) *Slot {
+	nearest := free[0]
+	for _, slot := range free[1:] {
+		if slot.Distance < nearest.Distance {
+			nearest = slot
+		}
+	}
+	return nearest
+}
+
+// SpreadAcrossFloors -> lowest slot of the floor with the most free slots, balances the load between floors
+type SpreadAcrossFloors struct{}
+
+func (SpreadAcrossFloors) Select(vtype VehicleType, free []*Slot) *Slot {
+	// free is sorted by floor, so every floor is a contiguous run
+	var best *Slot
+	bestCount := 0
+	for i := 0; i < len(free); {
+		j := i
+		for j < len(free) && free[j].FloorId == free[i].FloorId {
+			j++
+		}
+		if j-i > bestCount {
+			best, bestCount = free[i], j-i
+		}
+		i = j
+	}
+	return best
+}
+
+// HighestFloorFirst -> lowest slot of the highest floor, keeps the ground floors free at night
+type HighestFloorFirst struct{}
+
+func (HighestFloorFirst) Select(vtype VehicleType, free []*Slot) *Slot {
+	top := free[len(free)-1].FloorId
+	for _, slot := range free {
+		if slot.FloorId == top {
+			return slot
+		}
+	}
+	return free[len(free)-1]
+}
+
+// RandomSlot -> any free slot
+type RandomSlot struct {
+	mu  sync.Mutex
+	rnd *rand.Rand
+}
+
+// NewRandomSlot seeds the generator, the same seed gives the same sequence of picks
+func NewRandomSlot(seed uint64) *RandomSlot {
+	return &RandomSlot{rnd: rand.New(rand.NewPCG(seed, seed))}
+}
+
+func (r *RandomSlot) Select(vtype VehicleType, free []*Slot) *Slot {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return free[r.rnd.IntN(len(free))]
+}
+
+// contiguousStarts returns the slots of free starting a run of n adjacent free slots,
+// free is sorted so adjacent slots are neighbours in it
+func contiguousStarts(free []*Slot, n int) []*Slot {
+	if n <= 1 {
+		return free
+	}
+	starts := make([]*Slot, 0)
+	run := 1
+	for i := 1; i < len(free); i++ {
+		if free[i-1].adjacent(free[i]) {
+			run++
+		} else {
+			run = 1
+		}
+		if run >= n {
+			starts = append(starts, free[i-n+1])
+		}
+	}
+	return starts
+}
+
+// allocator wraps the strategy so it can be swapped atomically
+type allocator struct {
+	AllocationStrategy
+}
+
+// WithAllocationStrategy sets the strategy used by CheckIn (default LowestSlotFirst)
+func WithAllocationStrategy(strategy AllocationStrategy) Option {
+	return func(pl *ParkingLot) {
+		pl.SetAllocationStrategy(strategy)
+	}
+}
+
+// SetAllocationStrategy swaps the strategy at runtime, check ins already running keep the old one
+func (pl *ParkingLot) SetAllocationStrategy(strategy AllocationStrategy) {
+	if strategy == nil {
+		strategy = LowestSlotFirst{}
+	}
+	pl.allocation.Store(&allocator{strategy})
+}
+
+func (pl *ParkingLot) allocationStrategy() AllocationStrategy {
+	if current := pl.allocation.Load(); current != nil {
+		return current.AllocationStrategy
+	}
+	return LowestSlotFirst{}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+// allocationLayout has 3 floors of 3 Car slots, floor 1 starts next to the gate
+func allocationLayout() Layout {
+	distances := [][]int{{30, 20, 10}, {5, 50, 50}, {40, 40, 40}}
+	layout := Layout{Floors: make([]FloorLayout, len(distances))}
+	for i, floor := range distances {
+		for _, distance := range floor {
+			layout.Floors[i].Slots = append(layout.Floors[i].Slots, SlotSpec{Type: Car, Distance: distance})
+		}
+	}
+	return layout
+}
+
+// parkCars checks in n cars and returns the "floor-slot" they were given
+func parkCars(t *testing.T, lot *ParkingLot, n int) []string {
+	t.Helper()
+	var picks []string
+	for i := range n {
+		ticket, err := lot.CheckIn(Vehicle{RegistrationNumber: fmt.Sprintf("KA-01-%04d", i), Type: Car})
+		if err != nil {
+			t.Fatal(err)
+		}
+		picks = append(picks, slotKey(ticket.SlotDetails.FloorId, ticket.SlotDetails.Id))
+	}
+	return picks
+}
+
+func TestAllocationStrategies(t *testing.T) {
+	tests := []struct {
+		name     string
+		strategy AllocationStrategy
+		want     []string
+	}{
+		{"default", nil, []string{"0-0", "0-1", "0-2"}},
+		{"lowest slot first", LowestSlotFirst{}, []string{"0-0", "0-1", "0-2"}},
+		{"nearest to gate", NearestToGate{}, []string{"1-0", "0-2", "0-1"}},
+		{"spread across floors", SpreadAcrossFloors{}, []string{"0-0", "1-0", "2-0", "0-1"}},
+		{"highest floor first", HighestFloorFirst{}, []string{"2-0", "2-1", "2-2", "1-0"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lot, err := NewParkingLot(allocationLayout(), &NormalPricing{}, WithAllocationStrategy(tt.strategy))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := parkCars(t, lot, len(tt.want))
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Fatalf("picks %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRandomSlotIsSeeded(t *testing.T) {
+	var runs [2][]string
+	for i := range runs {
+		lot, err := NewParkingLot(allocationLayout(), &NormalPricing{}, WithAllocationStrategy(NewRandomSlot(7)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		runs[i] = parkCars(t, lot, 9)
+		// every slot handed out once
+		seen := make(map[string]bool)
+		for _, pick := range runs[i] {
+			if seen[pick] {
+				t.Fatalf("slot %s handed out twice: %v", pick, runs[i])
+			}
+			seen[pick] = true
+		}
+	}
+	if fmt.Sprint(runs[0]) != fmt.Sprint(runs[1]) {
+		t.Fatalf("same seed, different picks: %v and %v", runs[0], runs[1])
+	}
+}
+
+func TestSetAllocationStrategyAtRuntime(t *testing.T) {
+	lot, err := NewParkingLot(allocationLayout(), &NormalPricing{}, WithAllocationStrategy(HighestFloorFirst{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lot.SetAllocationStrategy(NearestToGate{})
+	lot.SetAllocationStrategy(nil)
+	if got := parkCars(t, lot, 1); got[0] != "0-0" {
+		t.Fatalf("pick %s after resetting the strategy, want 0-0", got[0])
+	}
+	lot.SetAllocationStrategy(NearestToGate{})
+	ticket, err := lot.CheckIn(Vehicle{RegistrationNumber: "KA-01-0001", Type: Car})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := slotKey(ticket.SlotDetails.FloorId, ticket.SlotDetails.Id); got != "1-0" {
+		t.Fatalf("pick %s with NearestToGate, want 1-0", got)
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+var ErrNoCharger = errors.New("slot has no charger")
+
+type ChargerPolicy int
+
+const (
+	// ChargersShared -> non EVs get a charger slot once the other slots of the pool are taken (default)
+	ChargersShared ChargerPolicy = iota
+	// ChargersEVOnly -> charger slots are kept for EVs
+	ChargersEVOnly
+)
+
+// WithChargerPolicy sets whether non EVs may park on charger slots (default ChargersShared)
+func WithChargerPolicy(policy ChargerPolicy) Option {
+	return func(pl *ParkingLot) {
+		pl.chargerPolicy = policy
+	}
+}
+
+// chargerCandidates returns the slots of free vtype may start parking on, the preferred kind first :
+// EVs try the charger slots then the others, non EVs the other way round unless chargers are EV only
+func (pl *ParkingLot) chargerCandidates(vtype VehicleType, free []*Slot, need int) []*Slot {
+	preference := []bool{false, true}
+	switch {
+	case vtype.IsElectric():
+		preference = []bool{true, false}
+	case pl.chargerPolicy == ChargersEVOnly:
+		preference = []bool{false}
+	}
+	for _, charger := range preference {
+		if candidates := contiguousStarts(withCharger(free, charger), need); len(candidates) > 0 {
+			return candidates
+		}
+	}
+	// a large vehicle may take a run mixing both kinds, e.g. a Bus on two Truck slots of which one has a charger
+	if need > 1 && !vtype.IsElectric() && pl.chargerPolicy == ChargersShared {
+		return contiguousStarts(free, need)
+	}
+	return nil
+}
+
+// withCharger keeps the slots of free whose Charger is charger, free itself is returned when they all match
+func withCharger(free []*Slot, charger bool) []*Slot {
+	matching := 0
+	for _, slot := range free {
+		if slot.Charger == charger {
+			matching++
+		}
+	}
+	if matching == len(free) {
+		return free
+	}
+	kept := make([]*Slot, 0, matching)
+	for _, slot := range free {
+		if slot.Charger == charger {
+			kept = append(kept, slot)
+		}
+	}
+	return kept
+}
+
+// ReportCharge is called by the charger of the slot with the energy delivered since its last report,
+// the total is billed at checkout by ChargingPricing
+func (pl *ParkingLot) ReportCharge(ticketID string, kWh float64) error {
+	if kWh <= 0 || math.IsNaN(kWh) || math.IsInf(kWh, 0) {
+		return fmt.Errorf("invalid energy %v kWh", kWh)
+	}
+	ticket, unlock, err := pl.lockTicket(ticketID)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if err := notActive(ticket); err != nil {
+		return err
+	}
+	if !ticket.SlotDetails.Charger {
+		return fmt.Errorf("%w: %d-%d", ErrNoCharger, ticket.SlotDetails.FloorId, ticket.SlotDetails.Id)
+	}
+
+	err = pl.journalEvent(JournalEvent{
+		Kind:      EventCharge,
+		Time:      pl.clock.Now().UnixNano(),
+		TicketId:  ticket.Id,
+		FloorId:   ticket.SlotDetails.FloorId,
+		SlotId:    ticket.SlotDetails.Id,
+		EnergyKWh: kWh,
+	})
+	if err != nil {
+		return err
+	}
+	ticket.EnergyKWh += kWh
+	return nil
+}
+
+// ChargingPricing bills the parking time with Base plus the energy delivered at its own tariff
+type ChargingPricing struct {
+	Base Pricing
+	// PricePerKWh is the energy tariff, the energy fee is rounded up
+	PricePerKWh int
+}
+
+func (ps *ChargingPricing) CalculatePrice(ticket ParkingTicket) Fee {
+	fee := ps.Base.CalculatePrice(ticket)
+	if ticket.EnergyKWh > 0 {
+		amount := int(math.Ceil(ticket.EnergyKWh * float64(ps.PricePerKWh)))
+		fee.add(fmt.Sprintf("Charging %.2f kWh @ %d/kWh", ticket.EnergyKWh, ps.PricePerKWh), amount)
+	}
+	return fee
+}
+
+// LockRate lets a surge Base keep working under the energy tariff
+func (ps *ChargingPricing) LockRate(vtype VehicleType, occupancy Occupancy) float64 {
+	if locker, ok := ps.Base.(RateLocker); ok {
+		return locker.LockRate(vtype, occupancy)
+	}
+	return 0
+}
+
+func (ps *ChargingPricing) NoShowFee(reservation Reservation) Fee {
+	return noShowFee(ps.Base, reservation)
+}
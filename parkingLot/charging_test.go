@@ -0,0 +1,140 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// chargerFloor is a Car floor whose slot 1 has a charger
+func chargerFloor() Layout {
+	return Layout{Floors: []FloorLayout{{Slots: []SlotSpec{{Type: Car}, {Type: Car, Charger: true}, {Type: Car}}}}}
+}
+
+func TestChargerSlotPreference(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy ChargerPolicy
+		// the vehicles park in order, want is the slot each gets, -1 when refused
+		vehicles []VehicleType
+		want     []int
+	}{
+		{"EV takes the charger", ChargersShared, []VehicleType{ElectricCar, ElectricCar}, []int{1, 0}},
+		{"cars keep off the charger", ChargersShared, []VehicleType{Car, Car, ElectricCar}, []int{0, 2, 1}},
+		{"shared charger once full", ChargersShared, []VehicleType{Car, Car, Car}, []int{0, 2, 1}},
+		{"EV only charger", ChargersEVOnly, []VehicleType{Car, Car, Car, ElectricCar}, []int{0, 2, -1, 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lot, err := NewParkingLot(chargerFloor(), &NormalPricing{}, WithChargerPolicy(tt.policy))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i, vtype := range tt.vehicles {
+				ticket, err := lot.CheckIn(Vehicle{RegistrationNumber: fmt.Sprintf("KA-01-%04d", i), Type: vtype})
+				if tt.want[i] < 0 {
+					if !errors.Is(err, ErrNoSlotAvailable) {
+						t.Fatalf("vehicle %d err = %v, want ErrNoSlotAvailable", i, err)
+					}
+					continue
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				if ticket.SlotDetails.Id != tt.want[i] {
+					t.Fatalf("vehicle %d parked in slot %d, want %d", i, ticket.SlotDetails.Id, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestReportChargeIsBilled(t *testing.T) {
+	clock := NewFakeClock(time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC))
+	pricing := &ChargingPricing{Base: &NormalPricing{Clock: clock}, PricePerKWh: 8}
+	lot, err := NewParkingLot(chargerFloor(), pricing, WithClock(clock))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ev, err := lot.CheckIn(Vehicle{RegistrationNumber: "KA-01-0001", Type: ElectricCar})
+	if err != nil {
+		t.Fatal(err)
+	}
+	car, err := lot.CheckIn(Vehicle{RegistrationNumber: "KA-01-0002", Type: Car})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, kWh := range []float64{4.5, 2.25} {
+		if err := lot.ReportCharge(ev.Id, kWh); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := lot.ReportCharge(ev.Id, -1); err == nil {
+		t.Fatal("negative energy accepted")
+	}
+	if err := lot.ReportCharge(car.Id, 1); !errors.Is(err, ErrNoCharger) {
+		t.Fatalf("charge on a plain slot err = %v, want ErrNoCharger", err)
+	}
+
+	clock.Advance(2 * time.Hour)
+	receipt, err := lot.Unpark(ev.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 2h parking + 6.75 kWh @ 8 = 54
+	if want := 2*ElectricCar.BasePrice() + 54; receipt.Fee.Total != want || len(receipt.Fee.Lines) != 2 {
+		t.Fatalf("fee %d (%+v), want %d", receipt.Fee.Total, receipt.Fee.Lines, want)
+	}
+	if err := lot.ReportCharge(ev.Id, 1); !errors.Is(err, ErrTicketClosed) {
+		t.Fatalf("charge after unpark err = %v, want ErrTicketClosed", err)
+	}
+}
+
+// two adjacent Truck slots, only the second one has a charger
+func mixedTruckFloor() Layout {
+	return Layout{Floors: []FloorLayout{{Slots: []SlotSpec{{Type: Truck}, {Type: Truck, Charger: true}}}}}
+}
+
+func TestBusOnMixedChargerRun(t *testing.T) {
+	lot, err := NewParkingLot(mixedTruckFloor(), &NormalPricing{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ticket, err := lot.CheckIn(Vehicle{RegistrationNumber: "KA-01-0001", Type: Bus})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if slots := ticket.occupiedSlots(); len(slots) != 2 || slots[0].Id != 0 || slots[1].Id != 1 {
+		t.Fatalf("bus parked on %+v, want slots 0 & 1", slots)
+	}
+}
+
+func TestBusKeptOffEVOnlyChargers(t *testing.T) {
+	lot, err := NewParkingLot(mixedTruckFloor(), &NormalPricing{}, WithChargerPolicy(ChargersEVOnly))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := lot.CheckIn(Vehicle{RegistrationNumber: "KA-01-0001", Type: Bus}); !errors.Is(err, ErrNoSlotAvailable) {
+		t.Fatalf("bus check in err = %v, want ErrNoSlotAvailable", err)
+	}
+}
+
+func TestReportChargeAfterPay(t *testing.T) {
+	lot, err := NewParkingLot(chargerFloor(), &NormalPricing{}, WithPaymentProcessor(NewFakeGateway()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ev, err := lot.CheckIn(Vehicle{RegistrationNumber: "KA-01-0001", Type: ElectricCar})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := lot.Pay(ev.Id, Payment{Method: PayCash, Tendered: 100}); err != nil {
+		t.Fatal(err)
+	}
+	// the fee is fixed once paid
+	if err := lot.ReportCharge(ev.Id, 1); !errors.Is(err, ErrTicketPaid) {
+		t.Fatalf("charge after pay err = %v, want ErrTicketPaid", err)
+	}
+}
@@ -0,0 +1,609 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CLI runs text commands against a parking lot, one command per line:
+//
+//	create_parking_lot PR123 3 6   (the new lot becomes the current one)
+//	use PR123                      (switch the current lot)
+//	park KA-01-1234 Car            (parks in the current lot)
+//	unpark PR123_2_5_1             (any lot, resolved from the ticket id, once paid if a fee is due)
+//	pay PR123_2_5_1 cash 100       (checkout paying cash, card <number> or wallet <id>, prints the receipt)
+//	topup W1 500                   (credits a wallet of the fake gateway)
+//	adjust PR123_2_5_1 -20 barrier fault   (after checkout, negative -> credit for the driver)
+//	refund PR123_2_5_1 20          (pays back a credit)
+//	wait KA-01-1234 Car            (joins the waitlist of the current lot, holds are printed as they come)
+//	claim PR123_W1                 (parks on the slot held for the waitlist entry)
+//	leave PR123_W1
+//	find KA-01-1234                (any lot)
+//	display free_count|free_slots|occupied_slots Car
+//	revenue 2026-01-01             (fees of the tickets closed that day, UTC)
+//	disable_slot 1 3 [drain]       (floor 1 slot 3, drain -> wait for the vehicle inside)
+//	enable_slot 1 3
+//	add_floor Car 6                (6 Car slots on a new top floor)
+//	remove_floor 1 [drain]
+//	advance 2h30m   (manual clock only)
+//
+// The lots report when a slot type gets full or available again after each command.
+// Blank lines and lines starting with # are skipped. Output only depends on the input
+// when the CLI runs on a FakeClock, which makes whole scenarios golden file testable
+type CLI struct {
+	out      io.Writer
+	clock    Clock
+	registry *LotRegistry
+	lot      *ParkingLot
+	// waiting -> waitlist entries joined from this CLI, by id
+	waiting map[string]waitingEntry
+	// boards -> LotFull & LotAvailable subscriptions of the lots created here
+	boards []*Subscription
+	// gateway takes the payments of every lot created here
+	gateway *FakeGateway
+}
+
+type waitingEntry struct {
+	lot   *ParkingLot
+	entry WaitlistEntry
+}
+
+var errNoLot = errors.New("no parking lot, run create_parking_lot first")
+
+func NewCLI(out io.Writer, clock Clock) *CLI {
+	return &CLI{out: out, clock: clock, registry: NewLotRegistry(), waiting: make(map[string]waitingEntry), gateway: NewFakeGateway()}
+}
+
+// Run executes every line of in, a failing command prints its error and the run goes on
+func (c *CLI) Run(in io.Reader) error {
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if line == "exit" {
+			return nil
+		}
+		if err := c.Exec(line); err != nil {
+			fmt.Fprintln(c.out, "Error:", err)
+		}
+		c.printBoards()
+		c.printHolds()
+	}
+	return scanner.Err()
+}
+
+// Exec runs a single command
+func (c *CLI) Exec(line string) error {
+	args := strings.Fields(line)
+	if len(args) == 0 {
+		return nil
+	}
+	cmd, args := args[0], args[1:]
+	switch cmd {
+	case "create_parking_lot":
+		return c.createParkingLot(args)
+	case "use":
+		return c.use(args)
+	case "park":
+		return c.park(args)
+	case "unpark":
+		return c.unpark(args)
+	case "pay":
+		return c.pay(args)
+	case "topup":
+		return c.topUp(args)
+	case "adjust":
+		return c.adjust(args)
+	case "refund":
+		return c.refund(args)
+	case "find":
+		return c.find(args)
+	case "wait":
+		return c.wait(args)
+	case "claim":
+		return c.claim(args)
+	case "leave":
+		return c.leave(args)
+	case "display":
+		return c.display(args)
+	case "advance":
+		return c.advance(args)
+	case "revenue":
+		return c.revenue(args)
+	case "disable_slot":
+		return c.disableSlot(args)
+	case "enable_slot":
+		return c.enableSlot(args)
+	case "add_floor":
+		return c.addFloor(args)
+	case "remove_floor":
+		return c.removeFloor(args)
+	default:
+		return fmt.Errorf("unknown command %q", cmd)
+	}
+}
+
+func expectArgs(cmd string, args []string, usage ...string) error {
+	if len(args) != len(usage) {
+		return fmt.Errorf("usage: %s %s", cmd, strings.Join(usage, " "))
+	}
+	return nil
+}
+
+func (c *CLI) createParkingLot(args []string) error {
+	if err := expectArgs("create_parking_lot", args, "<lotId>", "<floors>", "<slotsPerFloor>"); err != nil {
+		return err
+	}
+	floors, err := strconv.Atoi(args[1])
+	if err != nil || floors <= 0 {
+		return fmt.Errorf("invalid floor count %q", args[1])
+	}
+	slots, err := strconv.Atoi(args[2])
+	if err != nil || slots <= 0 {
+		return fmt.Errorf("invalid slot count %q", args[2])
+	}
+
+	lot, err := NewParkingLot(DefaultLayout(floors, slots), &NormalPricing{Clock: c.clock}, WithClock(c.clock), WithID(args[0]),
+		WithPaymentProcessor(c.gateway))
+	if err != nil {
+		return err
+	}
+	if err := c.registry.Register(lot); err != nil {
+		return err
+	}
+	c.lot = lot
+	c.boards = append(c.boards, lot.Subscribe(16, DropNewest, LotFull, LotAvailable))
+	fmt.Fprintf(c.out, "Created parking lot %s with %d floors and %d slots per floor\n", lot.ID(), floors, slots)
+	return nil
+}
+
+func (c *CLI) use(args []string) error {
+	if err := expectArgs("use", args, "<lotId>"); err != nil {
+		return err
+	}
+	lot, err := c.registry.Get(args[0])
+	if err != nil {
+		return err
+	}
+	c.lot = lot
+	fmt.Fprintf(c.out, "Using parking lot %s\n", lot.ID())
+	return nil
+}
+
+func (c *CLI) park(args []string) error {
+	if err := expectArgs("park", args, "<registrationNumber>", "<vehicleType>"); err != nil {
+		return err
+	}
+	if c.lot == nil {
+		return errNoLot
+	}
+	vtype, err := ParseVehicleType(args[1])
+	if err != nil {
+		return err
+	}
+	ticket, err := c.lot.CheckIn(Vehicle{RegistrationNumber: args[0], Type: vtype})
+	if errors.Is(err, ErrNoSlotAvailable) {
+		fmt.Fprintln(c.out, "Parking Lot Full")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(c.out, "Parked vehicle. Ticket ID: %s\n", ticket.Id)
+	return nil
+}
+
+func (c *CLI) unpark(args []string) error {
+	if err := expectArgs("unpark", args, "<ticketId>"); err != nil {
+		return err
+	}
+	receipt, err := c.registry.Unpark(args[0])
+	if errors.Is(err, ErrTicketNotFound) || errors.Is(err, ErrTicketClosed) || errors.Is(err, ErrLotNotFound) {
+		fmt.Fprintln(c.out, "Invalid Ticket")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(c.out, "Unparked vehicle with Registration Number: %s. Fee: %d\n", receipt.RegistrationNumber, receipt.Fee.Total)
+	return nil
+}
+
+func (c *CLI) pay(args []string) error {
+	if err := expectArgs("pay", args, "<ticketId>", "cash|card|wallet", "<tendered|number|walletId>"); err != nil {
+		return err
+	}
+	payment := Payment{Method: PaymentMethod(args[1]), Account: args[2]}
+	if payment.Method == PayCash {
+		tendered, err := strconv.Atoi(args[2])
+		if err != nil {
+			return fmt.Errorf("invalid cash amount %q", args[2])
+		}
+		payment = Payment{Method: PayCash, Tendered: tendered}
+	}
+	lot, err := c.registry.ResolveTicket(args[0])
+	if err != nil {
+		fmt.Fprintln(c.out, "Invalid Ticket")
+		return nil
+	}
+	ticket, err := lot.GetTicket(args[0])
+	if err != nil {
+		fmt.Fprintln(c.out, "Invalid Ticket")
+		return nil
+	}
+	receipt, err := lot.Checkout(ticket, payment)
+	if errors.Is(err, ErrTicketClosed) {
+		fmt.Fprintln(c.out, "Invalid Ticket")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(c.out, "Unparked vehicle with Registration Number: %s. Fee: %d\n", receipt.RegistrationNumber, receipt.Fee.Total)
+	c.printReceipt(receipt)
+	return nil
+}
+
+// printReceipt writes the fee lines and the ledger of a receipt
+func (c *CLI) printReceipt(receipt Receipt) {
+	for _, line := range receipt.Fee.Lines {
+		fmt.Fprintf(c.out, "  %-40s %6d\n", line.Description, line.Amount)
+	}
+	for _, tx := range receipt.Payments {
+		switch tx.Kind {
+		case TxPayment:
+			fmt.Fprintf(c.out, "  %-40s %6d\n", "Paid by "+string(tx.Method)+" ("+tx.Id+")", -tx.Amount)
+			if tx.Change > 0 {
+				fmt.Fprintf(c.out, "  %-40s %6d\n", "Change", tx.Change)
+			}
+		case TxRefund:
+			fmt.Fprintf(c.out, "  %-40s %6d\n", "Refunded by "+string(tx.Method)+" ("+tx.Id+")", tx.Amount)
+		case TxAdjustment:
+			fmt.Fprintf(c.out, "  %-40s %6d\n", "Adjustment: "+tx.Reason, tx.Amount)
+		}
+	}
+	fmt.Fprintf(c.out, "  %-40s %6d\n", "Balance", receipt.Balance)
+}
+
+func (c *CLI) topUp(args []string) error {
+	if err := expectArgs("topup", args, "<walletId>", "<amount>"); err != nil {
+		return err
+	}
+	amount, err := strconv.Atoi(args[1])
+	if err != nil || amount <= 0 {
+		return fmt.Errorf("invalid amount %q", args[1])
+	}
+	c.gateway.TopUp(args[0], amount)
+	fmt.Fprintf(c.out, "Wallet %s balance: %d\n", args[0], c.gateway.Balance(args[0]))
+	return nil
+}
+
+func (c *CLI) adjust(args []string) error {
+	if len(args) < 3 {
+		return errors.New("usage: adjust <ticketId> <amount> <reason>")
+	}
+	amount, err := strconv.Atoi(args[1])
+	if err != nil {
+		return fmt.Errorf("invalid amount %q", args[1])
+	}
+	lot, err := c.registry.ResolveTicket(args[0])
+	if err != nil {
+		return err
+	}
+	if _, err := lot.Adjust(args[0], amount, strings.Join(args[2:], " ")); err != nil {
+		return err
+	}
+	return c.printLedger(lot, args[0])
+}
+
+func (c *CLI) refund(args []string) error {
+	if err := expectArgs("refund", args, "<ticketId>", "<amount>"); err != nil {
+		return err
+	}
+	amount, err := strconv.Atoi(args[1])
+	if err != nil {
+		return fmt.Errorf("invalid amount %q", args[1])
+	}
+	lot, err := c.registry.ResolveTicket(args[0])
+	if err != nil {
+		return err
+	}
+	if _, err := lot.Refund(args[0], amount, ""); err != nil {
+		return err
+	}
+	return c.printLedger(lot, args[0])
+}
+
+func (c *CLI) printLedger(lot *ParkingLot, ticketID string) error {
+	receipt, err := lot.Receipt(ticketID)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(c.out, "Receipt %s:\n", ticketID)
+	c.printReceipt(receipt)
+	return nil
+}
+
+func (c *CLI) wait(args []string) error {
+	if err := expectArgs("wait", args, "<registrationNumber>", "<vehicleType>"); err != nil {
+		return err
+	}
+	if c.lot == nil {
+		return errNoLot
+	}
+	vtype, err := ParseVehicleType(args[1])
+	if err != nil {
+		return err
+	}
+	entry, err := c.lot.JoinWaitlist(Vehicle{RegistrationNumber: args[0], Type: vtype})
+	if err != nil {
+		return err
+	}
+	c.waiting[entry.Id] = waitingEntry{lot: c.lot, entry: entry}
+	fmt.Fprintf(c.out, "Added to waitlist. Wait ID: %s\n", entry.Id)
+	return nil
+}
+
+func (c *CLI) claim(args []string) error {
+	if err := expectArgs("claim", args, "<waitId>"); err != nil {
+		return err
+	}
+	waiting, exists := c.waiting[args[0]]
+	if !exists {
+		return fmt.Errorf("%w: %s", ErrWaitlistNotFound, args[0])
+	}
+	ticket, err := waiting.lot.CheckInWaitlist(args[0])
+	if err != nil {
+		return err
+	}
+	delete(c.waiting, args[0])
+	fmt.Fprintf(c.out, "Parked vehicle. Ticket ID: %s\n", ticket.Id)
+	return nil
+}
+
+func (c *CLI) leave(args []string) error {
+	if err := expectArgs("leave", args, "<waitId>"); err != nil {
+		return err
+	}
+	waiting, exists := c.waiting[args[0]]
+	if !exists {
+		return fmt.Errorf("%w: %s", ErrWaitlistNotFound, args[0])
+	}
+	if err := waiting.lot.LeaveWaitlist(args[0]); err != nil {
+		return err
+	}
+	delete(c.waiting, args[0])
+	fmt.Fprintf(c.out, "Left waitlist %s\n", args[0])
+	return nil
+}
+
+// printBoards reports the slot types that got full or available again during the last command
+func (c *CLI) printBoards() {
+	for _, board := range c.boards {
+		for pending := true; pending; {
+			select {
+			case ev := <-board.C:
+				if ev.Kind == LotFull {
+					fmt.Fprintf(c.out, "Parking lot %s is full for %s\n", ev.LotId, ev.VehicleType.ToString())
+				} else {
+					fmt.Fprintf(c.out, "Parking lot %s has %s slots again\n", ev.LotId, ev.VehicleType.ToString())
+				}
+			default:
+				pending = false
+			}
+		}
+	}
+}
+
+// printHolds reports the holds received since the last command, and the entries dropped on timeout
+func (c *CLI) printHolds() {
+	for _, id := range slices.Sorted(maps.Keys(c.waiting)) {
+		select {
+		case hold, open := <-c.waiting[id].entry.Holds:
+			if !open {
+				delete(c.waiting, id)
+				fmt.Fprintf(c.out, "Waitlist %s dropped, the hold expired\n", id)
+				continue
+			}
+			fmt.Fprintf(c.out, "Slot %d on floor %d held for %s until %s\n", hold.SlotId, hold.FloorId, id, hold.ExpiresAt.Format(time.TimeOnly))
+		default:
+		}
+	}
+}
+
+func (c *CLI) find(args []string) error {
+	if err := expectArgs("find", args, "<registrationNumber>"); err != nil {
+		return err
+	}
+	location, err := c.registry.FindVehicle(args[0])
+	if errors.Is(err, ErrVehicleNotFound) {
+		fmt.Fprintln(c.out, "Vehicle not found")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(c.out, "Vehicle %s is parked in lot %s, floor %d, slot %d. Ticket ID: %s\n", args[0], location.LotId, location.FloorId, location.SlotId, location.TicketId)
+	return nil
+}
+
+func (c *CLI) display(args []string) error {
+	if err := expectArgs("display", args, "free_count|free_slots|occupied_slots", "<vehicleType>"); err != nil {
+		return err
+	}
+	if c.lot == nil {
+		return errNoLot
+	}
+	vtype, err := ParseVehicleType(args[1])
+	if err != nil {
+		return err
+	}
+	switch args[0] {
+	case "free_count":
+		fmt.Fprintln(c.out, c.lot.DisplayFreeSlotCount(vtype))
+	case "free_slots":
+		fmt.Fprintln(c.out, c.lot.DisplayFreeSlots(vtype))
+	case "occupied_slots":
+		fmt.Fprintln(c.out, c.lot.DisplayOccupiedSlots(vtype))
+	default:
+		return fmt.Errorf("unknown display type %q", args[0])
+	}
+	return nil
+}
+
+func (c *CLI) advance(args []string) error {
+	if err := expectArgs("advance", args, "<duration>"); err != nil {
+		return err
+	}
+	clock, manual := c.clock.(*FakeClock)
+	if !manual {
+		return fmt.Errorf("advance needs the manual clock")
+	}
+	d, err := time.ParseDuration(args[0])
+	if err != nil {
+		return err
+	}
+	clock.Advance(d)
+	fmt.Fprintf(c.out, "Clock advanced by %v\n", d)
+	return nil
+}
+
+func (c *CLI) revenue(args []string) error {
+	if err := expectArgs("revenue", args, "<yyyy-mm-dd>"); err != nil {
+		return err
+	}
+	if c.lot == nil {
+		return errNoLot
+	}
+	day, err := time.Parse(time.DateOnly, args[0])
+	if err != nil {
+		return err
+	}
+	report := c.lot.Revenue(day, day.AddDate(0, 0, 1))
+	fmt.Fprintf(c.out, "Revenue for %s: %d (%d tickets)\n", args[0], report.Total, report.Tickets)
+	if report.NoShows > 0 {
+		fmt.Fprintf(c.out, "No-show fees: %d (%d reservations)\n", report.NoShowFees, report.NoShows)
+	}
+	return nil
+}
+
+// removalMode reads the optional trailing "drain" argument
+func removalMode(args []string, n int) (RemovalMode, error) {
+	switch {
+	case len(args) == n:
+		return RefuseOccupied, nil
+	case len(args) == n+1 && args[n] == "drain":
+		return Drain, nil
+	}
+	return RefuseOccupied, fmt.Errorf("unexpected arguments %q", args[min(n, len(args)):])
+}
+
+func parseInts(args ...string) ([]int, error) {
+	values := make([]int, len(args))
+	for i, arg := range args {
+		value, err := strconv.Atoi(arg)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q", arg)
+		}
+		values[i] = value
+	}
+	return values, nil
+}
+
+func (c *CLI) disableSlot(args []string) error {
+	if len(args) < 2 {
+		return fmt.Errorf("usage: disable_slot <floor> <slot> [drain]")
+	}
+	if c.lot == nil {
+		return errNoLot
+	}
+	mode, err := removalMode(args, 2)
+	if err != nil {
+		return err
+	}
+	ids, err := parseInts(args[0], args[1])
+	if err != nil {
+		return err
+	}
+	if err := c.lot.DisableSlot(ids[0], ids[1], mode); err != nil {
+		return err
+	}
+	fmt.Fprintf(c.out, "Disabled slot %d on floor %d\n", ids[1], ids[0])
+	return nil
+}
+
+func (c *CLI) enableSlot(args []string) error {
+	if err := expectArgs("enable_slot", args, "<floor>", "<slot>"); err != nil {
+		return err
+	}
+	if c.lot == nil {
+		return errNoLot
+	}
+	ids, err := parseInts(args[0], args[1])
+	if err != nil {
+		return err
+	}
+	if err := c.lot.EnableSlot(ids[0], ids[1]); err != nil {
+		return err
+	}
+	fmt.Fprintf(c.out, "Enabled slot %d on floor %d\n", ids[1], ids[0])
+	return nil
+}
+
+func (c *CLI) addFloor(args []string) error {
+	if err := expectArgs("add_floor", args, "<slotType>", "<slots>"); err != nil {
+		return err
+	}
+	if c.lot == nil {
+		return errNoLot
+	}
+	vtype, err := ParseVehicleType(args[0])
+	if err != nil {
+		return err
+	}
+	slots, err := strconv.Atoi(args[1])
+	if err != nil || slots <= 0 {
+		return fmt.Errorf("invalid slot count %q", args[1])
+	}
+	floor, err := c.lot.AddFloor(UniformFloor(vtype, slots))
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(c.out, "Added floor %d with %d %s slots\n", floor, slots, vtype.ToString())
+	return nil
+}
+
+func (c *CLI) removeFloor(args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("usage: remove_floor <floor> [drain]")
+	}
+	if c.lot == nil {
+		return errNoLot
+	}
+	mode, err := removalMode(args, 1)
+	if err != nil {
+		return err
+	}
+	floor, err := strconv.Atoi(args[0])
+	if err != nil {
+		return fmt.Errorf("invalid floor %q", args[0])
+	}
+	if err := c.lot.RemoveFloor(floor, mode); err != nil {
+		return err
+	}
+	if slices.Contains(c.lot.DrainingFloors(), floor) {
+		fmt.Fprintf(c.out, "Floor %d is draining\n", floor)
+		return nil
+	}
+	fmt.Fprintf(c.out, "Removed floor %d\n", floor)
+	return nil
+}
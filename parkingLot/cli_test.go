@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCLIParkAndUnpark(t *testing.T) {
+	var out bytes.Buffer
+	cli := NewCLI(&out, NewFakeClock(manualClockStart))
+	script := `
+# one floor, a single Car slot
+create_parking_lot PR1 1 1
+park KA-01-0001 Car
+park KA-01-0002 Car
+advance 90m
+`
+	if err := cli.Run(strings.NewReader(script)); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 5 || lines[2] != "Parking lot PR1 is full for Car" || lines[3] != "Parking Lot Full" || lines[4] != "Clock advanced by 1h30m0s" {
+		t.Fatalf("output:\n%s", out.String())
+	}
+	ticketID, parked := strings.CutPrefix(lines[1], "Parked vehicle. Ticket ID: ")
+	if !parked {
+		t.Fatalf("no ticket in %q", lines[1])
+	}
+
+	out.Reset()
+	// the fake gateway is set, the fee is paid before the vehicle leaves
+	if err := cli.Exec("unpark " + ticketID); !errors.Is(err, ErrPaymentRequired) {
+		t.Fatalf("unpark of an unpaid ticket err = %v, want ErrPaymentRequired", err)
+	}
+	for _, line := range []string{"pay " + ticketID + " cash 100", "unpark " + ticketID, "unpark nope"} {
+		if err := cli.Exec(line); err != nil {
+			t.Fatalf("%s: %v", line, err)
+		}
+	}
+	paid, rest, _ := strings.Cut(out.String(), "\n")
+	if paid != "Unparked vehicle with Registration Number: KA-01-0001. Fee: 40" || !strings.HasSuffix(rest, "\nInvalid Ticket\nInvalid Ticket\n") {
+		t.Fatalf("output:\n%s", out.String())
+	}
+}
+
+func TestCLIErrors(t *testing.T) {
+	var out bytes.Buffer
+	cli := NewCLI(&out, SystemClock)
+	tests := []struct {
+		line string
+		want string
+	}{
+		{"park KA-01-0001 Car", errNoLot.Error()},
+		{"fly away", `unknown command "fly"`},
+		{"create_parking_lot PR1 0 6", `invalid floor count "0"`},
+		{"create_parking_lot PR1 2", "usage: create_parking_lot <lotId> <floors> <slotsPerFloor>"},
+		{"advance 1h", "advance needs the manual clock"},
+	}
+	for _, tt := range tests {
+		if err := cli.Exec(tt.line); err == nil || err.Error() != tt.want {
+			t.Errorf("%s: err = %v, want %q", tt.line, err, tt.want)
+		}
+	}
+
+	// Run reports the failing command and goes on
+	out.Reset()
+	if err := cli.Run(strings.NewReader("fly away\ncreate_parking_lot PR1 1 6\nexit\npark KA-01-0001 Car\n")); err != nil {
+		t.Fatal(err)
+	}
+	want := "Error: unknown command \"fly\"\nCreated parking lot PR1 with 1 floors and 6 slots per floor\n"
+	if out.String() != want {
+		t.Fatalf("output:\n%s\nwant:\n%s", out.String(), want)
+	}
+}
+
+var update = flag.Bool("update", false, "rewrite the golden files of the tests")
+
+// example_commands.txt run under -manual-clock, go test -run Golden -update refreshes the golden file
+func TestExampleCommandsGolden(t *testing.T) {
+	in, err := os.Open("example_commands.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer in.Close()
+
+	var out bytes.Buffer
+	if err := NewCLI(&out, NewFakeClock(manualClockStart)).Run(in); err != nil {
+		t.Fatal(err)
+	}
+
+	golden := filepath.Join("testdata", "example_commands.golden")
+	if *update {
+		if err := os.WriteFile(golden, out.Bytes(), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out.Bytes(), want) {
+		t.Fatalf("output differs from %s, rerun with -update if intended:\n%s", golden, out.String())
+	}
+}
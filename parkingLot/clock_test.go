@@ -0,0 +1,108 @@
+package main
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestFakeClockAdvanceAndSet(t *testing.T) {
+	start := time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+	if !clock.Now().Equal(start) {
+		t.Fatalf("Now() = %v, want %v", clock.Now(), start)
+	}
+	clock.Advance(90 * time.Minute)
+	if got := clock.Now().Sub(start); got != 90*time.Minute {
+		t.Fatalf("advanced by %v, want 1h30m", got)
+	}
+	clock.Set(start)
+	if !clock.Now().Equal(start) {
+		t.Fatalf("Now() after Set = %v, want %v", clock.Now(), start)
+	}
+	if orSystemClock(nil) != SystemClock || orSystemClock(clock) != clock {
+		t.Fatalf("orSystemClock does not default to SystemClock")
+	}
+}
+
+// the lot stamps tickets with its clock, the pricing quotes an active ticket at its own clock
+func TestLotAndPricingFollowTheClock(t *testing.T) {
+	clock := NewFakeClock(time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC))
+	pricing := &NormalPricing{Clock: clock}
+	lot, err := NewParkingLot(Layout{Floors: []FloorLayout{UniformFloor(Car, 1)}}, pricing, WithClock(clock))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ticket, err := lot.CheckIn(Vehicle{RegistrationNumber: "KA-01-0001", Type: Car})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clock.Advance(90 * time.Minute)
+	if quote := pricing.CalculatePrice(ticket); quote.Total != 2*Car.BasePrice() {
+		t.Fatalf("quote after 1h30 = %d (%+v), want %d", quote.Total, quote.Lines, 2*Car.BasePrice())
+	}
+	clock.Advance(time.Hour)
+	receipt, err := lot.Unpark(ticket.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if receipt.CheckoutTime-receipt.CheckinTime != int64(150*time.Minute) || receipt.Fee.Total != 3*Car.BasePrice() {
+		t.Fatalf("receipt %+v, want a 2h30 stay billed %d", receipt, 3*Car.BasePrice())
+	}
+}
+
+func TestFakeClockFiresTimersInOrder(t *testing.T) {
+	start := time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+	var fired []time.Duration
+	record := func() { fired = append(fired, clock.Now().Sub(start)) }
+
+	clock.AfterFunc(2*time.Hour, record)
+	clock.AfterFunc(time.Hour, record)
+	stop := clock.AfterFunc(90*time.Minute, record)
+	if !stop() {
+		t.Fatal("stop of a pending timer reported false")
+	}
+	clock.AfterFunc(3*time.Hour, record)
+
+	clock.Advance(150 * time.Minute)
+	if want := []time.Duration{time.Hour, 2 * time.Hour}; !slices.Equal(fired, want) {
+		t.Fatalf("fired at %v, want %v", fired, want)
+	}
+	if got := clock.Now().Sub(start); got != 150*time.Minute {
+		t.Fatalf("clock at %v after Advance, want 2h30m", got)
+	}
+	if stop() {
+		t.Fatal("stop of a cancelled timer reported true")
+	}
+}
+
+func TestMultiDayStayWithoutWaiting(t *testing.T) {
+	clock := NewFakeClock(time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC))
+	pricing := &MeteredPricing{Policy: BillingPolicy{Unit: time.Hour, Rounding: RoundUp, DailyCap: 200}, Clock: clock}
+	lot, err := NewParkingLot(Layout{Floors: []FloorLayout{UniformFloor(Car, 1)}}, pricing, WithClock(clock))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ticket, err := lot.CheckIn(Vehicle{RegistrationNumber: "KA-01-0001", Type: Car})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ticket.CheckinTime != clock.Now().UnixNano() {
+		t.Fatalf("checked in at %d, clock at %d", ticket.CheckinTime, clock.Now().UnixNano())
+	}
+
+	// 2 full days capped at 200 each, then 90 minutes -> 2 started hours
+	clock.Advance(2*day + 90*time.Minute)
+	receipt, err := lot.Unpark(ticket.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := 2*200 + 2*20; receipt.Fee.Total != want {
+		t.Fatalf("fee %d (%+v), want %d", receipt.Fee.Total, receipt.Fee.Lines, want)
+	}
+	if receipt.CheckoutTime != clock.Now().UnixNano() {
+		t.Fatalf("checked out at %d, clock at %d", receipt.CheckoutTime, clock.Now().UnixNano())
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import "slices"
+
+// Compatibility lists, per vehicle type, the slot types it may park in by order of preference.
+// A vehicle type missing from the matrix only parks in slots of its VehicleType.SlotType
+type Compatibility map[VehicleType][]VehicleType
+
+// DefaultCompatibility lets smaller vehicles fall back to larger slots once their own type is full
+func DefaultCompatibility() Compatibility {
+	return Compatibility{
+		Bike:        {Bike, Car, Truck},
+		Car:         {Car, Truck},
+		Truck:       {Truck},
+		Bus:         {Truck},
+		Trailer:     {Truck},
+		ElectricCar: {Car, Truck},
+	}
+}
+
+// ExactCompatibility only allows a vehicle in slots of its own slot type
+func ExactCompatibility() Compatibility {
+	return Compatibility{}
+}
+
+// slotTypes returns the slot types vtype may use, its own slot type when the matrix does not say otherwise
+func (c Compatibility) slotTypes(vtype VehicleType) []VehicleType {
+	if types, exists := c[vtype]; exists && len(types) > 0 {
+		return types
+	}
+	return []VehicleType{vtype.SlotType()}
+}
+
+type FeeBasis int
+
+const (
+	// FeeByVehicle bills the vehicle type, whatever slot it ends up in (default)
+	FeeByVehicle FeeBasis = iota
+	// FeeBySlot bills the type of the slot, a Bike in a Car slot pays the Car rate
+	FeeBySlot
+)
+
+// WithCompatibility sets the fallback matrix (default DefaultCompatibility)
+func WithCompatibility(c Compatibility) Option {
+	return func(pl *ParkingLot) {
+		pl.compatibility = c
+	}
+}
+
+// WithFeeBasis sets which type a fallback parking is billed at (default FeeByVehicle)
+func WithFeeBasis(basis FeeBasis) Option {
+	return func(pl *ParkingLot) {
+		pl.feeBasis = basis
+	}
+}
+
+// billedAs is the type the pricing charges for vehicle parked in slot
+func (pl *ParkingLot) billedAs(vehicle Vehicle, slot *Slot) VehicleType {
+	// a large vehicle is always billed as itself, it pays for the slots it takes
+	if pl.feeBasis == FeeBySlot && vehicle.Type.SlotsRequired() == 1 {
+		return slot.Type
+	}
+	return vehicle.Type
+}
+
+// lockPools takes the locks of every pool in ascending type order so two check ins
+// sharing pools can not deadlock, the returned func releases them
+func (pl *ParkingLot) lockPools(pools []VehicleType) func() {
+	sorted := slices.Clone(pools)
+	slices.Sort(sorted)
+	sorted = slices.Compact(sorted)
+	for _, vtype := range sorted {
+		pl.getLock(vtype).Lock()
+	}
+	return func() {
+		for i := len(sorted) - 1; i >= 0; i-- {
+			pl.getLock(sorted[i]).Unlock()
+		}
+	}
+}
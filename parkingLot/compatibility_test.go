@@ -0,0 +1,99 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// oneOfEachLayout is a single floor with a Bike, a Car and a Truck slot
+func oneOfEachLayout() Layout {
+	return Layout{Floors: []FloorLayout{{Slots: []SlotSpec{{Type: Bike}, {Type: Car}, {Type: Truck}}}}}
+}
+
+func TestCompatibilityFallbackOrder(t *testing.T) {
+	tests := []struct {
+		name   string
+		matrix Compatibility
+		// slot types the successive bikes get, parking stops at the first refusal
+		want []VehicleType
+	}{
+		{"default", DefaultCompatibility(), []VehicleType{Bike, Car, Truck}},
+		{"exact", ExactCompatibility(), []VehicleType{Bike}},
+		{"custom order", Compatibility{Bike: {Truck, Bike}}, []VehicleType{Truck, Bike}},
+		{"empty list", Compatibility{Bike: {}}, []VehicleType{Bike}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lot, err := NewParkingLot(oneOfEachLayout(), &NormalPricing{}, WithCompatibility(tt.matrix))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []VehicleType
+			for i := range 4 {
+				ticket, err := lot.CheckIn(Vehicle{RegistrationNumber: fmt.Sprintf("KA-01-%04d", i), Type: Bike})
+				if errors.Is(err, ErrNoSlotAvailable) {
+					break
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				got = append(got, ticket.SlotDetails.Type)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Fatalf("bikes parked in %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLargerVehiclesDoNotFallBackDown(t *testing.T) {
+	lot, err := NewParkingLot(oneOfEachLayout(), &NormalPricing{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := lot.CheckIn(Vehicle{RegistrationNumber: "KA-01-0001", Type: Truck}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := lot.CheckIn(Vehicle{RegistrationNumber: "KA-01-0002", Type: Truck}); !errors.Is(err, ErrNoSlotAvailable) {
+		t.Fatalf("second truck err = %v, want ErrNoSlotAvailable", err)
+	}
+	// the truck did not take anything from the smaller pools
+	if lot.FreeSlotCount(Bike) != 1 || lot.FreeSlotCount(Car) != 1 {
+		t.Fatalf("free bike %d, car %d slots, want 1 each", lot.FreeSlotCount(Bike), lot.FreeSlotCount(Car))
+	}
+}
+
+func TestFeeBasis(t *testing.T) {
+	for _, tt := range []struct {
+		basis FeeBasis
+		want  int
+	}{
+		{FeeByVehicle, 2 * Bike.BasePrice()},
+		{FeeBySlot, 2 * Car.BasePrice()},
+	} {
+		clock := NewFakeClock(time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC))
+		layout := Layout{Floors: []FloorLayout{{Slots: []SlotSpec{{Type: Car}}}}}
+		lot, err := NewParkingLot(layout, &NormalPricing{Clock: clock}, WithClock(clock), WithFeeBasis(tt.basis))
+		if err != nil {
+			t.Fatal(err)
+		}
+		// no Bike slot, the bike parks in the Car slot
+		ticket, err := lot.CheckIn(Vehicle{RegistrationNumber: "KA-01-0001", Type: Bike})
+		if err != nil {
+			t.Fatal(err)
+		}
+		clock.Advance(2 * time.Hour)
+		receipt, err := lot.Unpark(ticket.Id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if receipt.Fee.Total != tt.want {
+			t.Errorf("basis %d: fee %d (%+v), want %d", tt.basis, receipt.Fee.Total, receipt.Fee.Lines, tt.want)
+		}
+		if lot.FreeSlotCount(Car) != 1 {
+			t.Errorf("basis %d: the Car slot is not free after unpark", tt.basis)
+		}
+	}
+}
@@ -1,10 +1,20 @@
 package main
 
+import (
+	"fmt"
+	"strings"
+)
+
 type VehicleType int
 const (
 	Bike VehicleType = iota 
 	Car 
 	Truck 
+	// Bus & Trailer take several contiguous Truck slots
+	Bus
+	Trailer
+	// ElectricCar parks in Car slots and prefers the ones with a charger
+	ElectricCar
 )
 func (v VehicleType) BasePrice() int {
     switch v {
@@ -14,6 +24,12 @@ func (v VehicleType) BasePrice() int {
         return 20
     case Truck:
         return 30
+    case Bus:
+        return 60
+    case Trailer:
+        return 90
+    case ElectricCar:
+        return 20
     default:
         return 0
     }
@@ -26,11 +42,79 @@ func (v VehicleType) ToString() string {
         return "Car"
     case Truck:
         return "Truck"
+    case Bus:
+        return "Bus"
+    case Trailer:
+        return "Trailer"
+    case ElectricCar:
+        return "ElectricCar"
     default:
         return ""
     }
 }
 
+// SlotType is the slot type the vehicle naturally parks in
+func (v VehicleType) SlotType() VehicleType {
+	switch v {
+	case Bus, Trailer:
+		return Truck
+	case ElectricCar:
+		return Car
+	default:
+		return v
+	}
+}
+
+// SlotsRequired is the number of contiguous slots (same floor, consecutive ids) the vehicle takes
+func (v VehicleType) SlotsRequired() int {
+	switch v {
+	case Bus:
+		return 2
+	case Trailer:
+		return 3
+	default:
+		return 1
+	}
+}
+
+// IsElectric -> the vehicle can use the charger of a slot
+func (v VehicleType) IsElectric() bool {
+	return v == ElectricCar
+}
+
+// vehicleTypes lists every known vehicle type in ascending order
+var vehicleTypes = []VehicleType{Bike, Car, Truck, Bus, Trailer, ElectricCar}
+
+// slotTypes lists the types a slot can be built for
+var slotTypes = []VehicleType{Bike, Car, Truck}
+
+// ParseVehicleType is the inverse of ToString, matching is case insensitive
+func ParseVehicleType(name string) (VehicleType, error) {
+	for _, v := range vehicleTypes {
+		if strings.EqualFold(v.ToString(), name) {
+			return v, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown vehicle type %q", name)
+}
+
+func (v VehicleType) MarshalText() ([]byte, error) {
+	name := v.ToString()
+	if name == "" {
+		return nil, fmt.Errorf("unknown vehicle type %d", int(v))
+	}
+	return []byte(name), nil
+}
+
+func (v *VehicleType) UnmarshalText(text []byte) error {
+	parsed, err := ParseVehicleType(string(text))
+	if err != nil {
+		return err
+	}
+	*v = parsed
+	return nil
+}
+
 
 type Vehicle struct {
 	RegistrationNumber string
@@ -41,15 +125,29 @@ type Slot struct {
 	IsOccupied bool
 	Id int
 	FloorId int
+	// Type is the vehicle type this slot is built for, set from the layout
+	Type VehicleType
+	// Distance from the entry gate, set from the layout
+	Distance int
+	// Charger -> the slot has an EV charger, set from the layout
+	Charger bool
+	// HeldBy is the id of the reservation keeping this free slot out of the availability index
+	HeldBy string
+	// Disabled -> closed for maintenance, an occupied disabled slot is draining and is not given out once freed
+	Disabled bool
 }
 func (s *Slot) GetVehicleType() VehicleType {
-    if s.Id == 1 {
-		return Truck
-	} 
-	if s.Id > 1 && s.Id <= 4 {
-		return Bike
-	}
-	return Car
+	return s.Type
+}
+
+// available -> the slot can be given out, it is in the availability index
+func (s *Slot) available() bool {
+	return !s.IsOccupied && s.HeldBy == "" && !s.Disabled
+}
+
+// adjacent -> next is the slot right after s on the same floor
+func (s *Slot) adjacent(next *Slot) bool {
+	return s.FloorId == next.FloorId && s.Id+1 == next.Id
 }
 
 type ParkingFloor struct {
@@ -57,10 +155,74 @@ type ParkingFloor struct {
 	FloorNum int
 }
 
+type TicketStatus int
+const (
+	// TicketActive -> vehicle is inside the lot
+	TicketActive TicketStatus = iota
+	// TicketPaid -> fee is settled at the pay station, the vehicle is still inside (see Pay)
+	TicketPaid
+	// TicketClosed -> slot released, ticket can not be used again
+	TicketClosed
+)
+func (s TicketStatus) ToString() string {
+	switch s {
+	case TicketActive:
+		return "ACTIVE"
+	case TicketPaid:
+		return "PAID"
+	case TicketClosed:
+		return "CLOSED"
+	default:
+		return ""
+	}
+}
+
 type ParkingTicket struct {
+	// Id is <lotId>_<floor>_<slot>_<visit> (see TicketRef), every visit of a slot gets its own id
 	Id string
+	// Visit numbers the tickets issued by the lot, it is the last part of Id
+	Visit uint64
 	VehicleParked *Vehicle
 	CheckinTime int64
+	// SlotDetails is the first slot, Slots holds every slot taken (more than one for large vehicles)
 	SlotDetails *Slot
+	Slots []*Slot
+	Status TicketStatus
+	// BilledAs is the type the pricing charges, the vehicle type or the slot type (see FeeBasis)
+	BilledAs VehicleType
+	// RateMultiplier is locked at check in by surge pricing, 0 means no surge
+	RateMultiplier float64
+	// EnergyKWh is the energy delivered by the charger of the slot, see ReportCharge
+	EnergyKWh float64
+	// Coupon is the code taken off the fee at checkout, see ApplyCoupon
+	Coupon string
+	// PaidTime is set by Pay, the fee is then fixed
+	PaidTime int64
+	// CheckoutTime & Fee are set once the ticket is unparked
+	CheckoutTime int64
+	Fee Fee
+	// Payments is the ledger of the ticket : the payment taken by Checkout, then refunds & adjustments
+	Payments []Transaction
+}
+
+// Receipt is handed out on Pay & Unpark, CheckoutTime is 0 while the vehicle is still inside
+type Receipt struct {
+	TicketId string
+	RegistrationNumber string
+	VehicleType VehicleType
+	FloorId int
+	SlotId int
+	// SlotIds -> every slot on FloorId the vehicle took
+	SlotIds []int
+	CheckinTime int64
+	PaidTime int64
+	CheckoutTime int64
+	EnergyKWh float64
+	Fee Fee
+	// Discounts -> the discount lines of Fee
+	Discounts []FeeLine
+	// Payments -> the ledger of the ticket, Balance -> what is still due, negative when the lot owes the driver
+	Payments []Transaction
+	Balance int
 }
 
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type EventKind int
+
+const (
+	VehicleParked EventKind = iota
+	VehicleLeft
+	// LotFull -> the last free slot of a slot type was taken
+	LotFull
+	// LotAvailable -> a slot type had no free slot and one got free
+	LotAvailable
+	SlotDisabled
+	SlotEnabled
+)
+
+func (k EventKind) ToString() string {
+	switch k {
+	case VehicleParked:
+		return "VEHICLE_PARKED"
+	case VehicleLeft:
+		return "VEHICLE_LEFT"
+	case LotFull:
+		return "LOT_FULL"
+	case LotAvailable:
+		return "LOT_AVAILABLE"
+	case SlotDisabled:
+		return "SLOT_DISABLED"
+	case SlotEnabled:
+		return "SLOT_ENABLED"
+	default:
+		return ""
+	}
+}
+
+func (k EventKind) MarshalText() ([]byte, error) {
+	name := k.ToString()
+	if name == "" {
+		return nil, fmt.Errorf("unknown event kind %d", int(k))
+	}
+	return []byte(name), nil
+}
+
+// Event is published to the subscribers of the lot. VehicleType is the vehicle type for
+// VehicleParked & VehicleLeft, the slot type otherwise
+type Event struct {
+	Kind        EventKind   `json:"kind"`
+	Time        time.Time   `json:"time"`
+	LotId       string      `json:"lotId"`
+	VehicleType VehicleType `json:"vehicleType"`
+	FloorId     int         `json:"floor"`
+	SlotId      int         `json:"slot"`
+	// TicketId & RegistrationNumber are only set for VehicleParked & VehicleLeft
+	TicketId           string `json:"ticketId,omitempty"`
+	RegistrationNumber string `json:"registrationNumber,omitempty"`
+}
+
+type DropPolicy int
+
+const (
+	// DropNewest -> a full subscriber misses the new events (default)
+	DropNewest DropPolicy = iota
+	// DropOldest -> a full subscriber loses its oldest pending event to make room, e.g. for display boards
+	DropOldest
+)
+
+// Subscription receives the events of the lot on C, events are never waited for :
+// once the buffer is full the drop policy applies
+type Subscription struct {
+	C <-chan Event
+
+	ch      chan Event
+	kinds   []EventKind
+	policy  DropPolicy
+	mu      sync.Mutex
+	closed  bool
+	dropped atomic.Uint64
+}
+
+// Dropped counts the events the subscriber missed
+func (s *Subscription) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+func (s *Subscription) deliver(ev Event) {
+	if len(s.kinds) > 0 && !slices.Contains(s.kinds, ev.Kind) {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	select {
+	case s.ch <- ev:
+		return
+	default:
+	}
+	// either the oldest pending event or ev is lost
+	s.dropped.Add(1)
+	if s.policy == DropOldest {
+		select {
+		case <-s.ch:
+		default:
+		}
+		select {
+		case s.ch <- ev:
+		default:
+		}
+	}
+}
+
+// eventBus fans the events out, the zero value is ready to use
+type eventBus struct {
+	mu   sync.RWMutex
+	subs []*Subscription
+}
+
+// Subscribe returns a subscription buffering up to buffer events, kinds filters the events (none -> all)
+func (pl *ParkingLot) Subscribe(buffer int, policy DropPolicy, kinds ...EventKind) *Subscription {
+	ch := make(chan Event, max(buffer, 1))
+	sub := &Subscription{C: ch, ch: ch, kinds: slices.Clone(kinds), policy: policy}
+	pl.events.mu.Lock()
+	defer pl.events.mu.Unlock()
+	pl.events.subs = append(pl.events.subs, sub)
+	return sub
+}
+
+// Unsubscribe stops the deliveries and closes C
+func (pl *ParkingLot) Unsubscribe(sub *Subscription) {
+	pl.events.mu.Lock()
+	pl.events.subs = slices.DeleteFunc(pl.events.subs, func(s *Subscription) bool { return s == sub })
+	pl.events.mu.Unlock()
+
+	sub.mu.Lock()
+	defer sub.mu.Unlock()
+	if !sub.closed {
+		sub.closed = true
+		close(sub.ch)
+	}
+}
+
+// publish never blocks, it is called with the lot locks held so the events of a pool keep their order
+func (pl *ParkingLot) publish(ev Event) {
+	pl.events.mu.RLock()
+	defer pl.events.mu.RUnlock()
+	if len(pl.events.subs) == 0 {
+		return
+	}
+	ev.LotId = pl.id
+	ev.Time = pl.clock.Now()
+	for _, sub := range pl.events.subs {
+		sub.deliver(ev)
+	}
+}
+
+func (pl *ParkingLot) publishTicket(kind EventKind, ticket *ParkingTicket) {
+	pl.publish(Event{
+		Kind:               kind,
+		VehicleType:        ticket.VehicleParked.Type,
+		FloorId:            ticket.SlotDetails.FloorId,
+		SlotId:             ticket.SlotDetails.Id,
+		TicketId:           ticket.Id,
+		RegistrationNumber: ticket.VehicleParked.RegistrationNumber,
+	})
+}
+
+func (pl *ParkingLot) publishSlot(kind EventKind, slot *Slot) {
+	pl.publish(Event{Kind: kind, VehicleType: slot.Type, FloorId: slot.FloorId, SlotId: slot.Id})
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// drain returns the kinds of the events pending on sub
+func drain(sub *Subscription) []EventKind {
+	var kinds []EventKind
+	for {
+		select {
+		case ev := <-sub.C:
+			kinds = append(kinds, ev.Kind)
+		default:
+			return kinds
+		}
+	}
+}
+
+func sameKinds(got []EventKind, want []EventKind) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestSubscribeLotFullAndAvailable(t *testing.T) {
+	clock := NewFakeClock(time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC))
+	lot, err := NewParkingLot(Layout{Floors: []FloorLayout{UniformFloor(Car, 2)}}, &NormalPricing{Clock: clock},
+		WithID("PR123"), WithClock(clock))
+	if err != nil {
+		t.Fatal(err)
+	}
+	all := lot.Subscribe(16, DropNewest)
+	transitions := lot.Subscribe(16, DropNewest, LotFull, LotAvailable)
+
+	first, _ := lot.CheckIn(Vehicle{RegistrationNumber: "KA-01-0001", Type: Car})
+	lot.CheckIn(Vehicle{RegistrationNumber: "KA-01-0002", Type: Car})
+	lot.Unpark(first.Id)
+
+	want := []EventKind{VehicleParked, VehicleParked, LotFull, VehicleLeft, LotAvailable}
+	ev := <-all.C
+	if ev.Kind != VehicleParked || ev.LotId != "PR123" || ev.TicketId != first.Id || ev.RegistrationNumber != "KA-01-0001" ||
+		ev.VehicleType != Car || !ev.Time.Equal(clock.Now()) {
+		t.Fatalf("first event %+v", ev)
+	}
+	if got := drain(all); !sameKinds(got, want[1:]) {
+		t.Fatalf("events %v, want %v", got, want[1:])
+	}
+	if got := drain(transitions); !sameKinds(got, []EventKind{LotFull, LotAvailable}) {
+		t.Fatalf("filtered events %v, want LotFull then LotAvailable", got)
+	}
+
+	lot.Unsubscribe(all)
+	lot.Unsubscribe(all)
+	if _, open := <-all.C; open {
+		t.Fatal("C still open after Unsubscribe")
+	}
+	lot.CheckIn(Vehicle{RegistrationNumber: "KA-01-0003", Type: Car})
+	if got := drain(transitions); !sameKinds(got, []EventKind{LotFull}) {
+		t.Fatalf("filtered events %v, want LotFull", got)
+	}
+}
+
+func TestSlowSubscriberDropPolicies(t *testing.T) {
+	lot, err := NewParkingLot(Layout{Floors: []FloorLayout{UniformFloor(Car, 4)}}, &NormalPricing{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	newest := lot.Subscribe(2, DropNewest, VehicleParked)
+	oldest := lot.Subscribe(2, DropOldest, VehicleParked)
+
+	var tickets []string
+	for _, registration := range []string{"KA-01-0001", "KA-01-0002", "KA-01-0003", "KA-01-0004"} {
+		ticket, err := lot.CheckIn(Vehicle{RegistrationNumber: registration, Type: Car})
+		if err != nil {
+			t.Fatal(err)
+		}
+		tickets = append(tickets, ticket.Id)
+	}
+
+	for _, tt := range []struct {
+		name string
+		sub  *Subscription
+		want []string
+	}{
+		{"DropNewest", newest, tickets[:2]},
+		{"DropOldest", oldest, tickets[2:]},
+	} {
+		if tt.sub.Dropped() != 2 {
+			t.Errorf("%s: dropped %d, want 2", tt.name, tt.sub.Dropped())
+		}
+		for _, want := range tt.want {
+			if ev := <-tt.sub.C; ev.TicketId != want {
+				t.Errorf("%s: got ticket %s, want %s", tt.name, ev.TicketId, want)
+			}
+		}
+	}
+}
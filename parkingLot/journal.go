@@ -0,0 +1,412 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+type JournalEventKind string
+
+const (
+	EventCheckIn  JournalEventKind = "check_in"
+	EventCheckOut JournalEventKind = "check_out"
+	// EventPaid fixes the fee of a vehicle still inside and carries its payment
+	EventPaid JournalEventKind = "paid"
+	// EventCharge adds the energy reported by a charger to the ticket
+	EventCharge JournalEventKind = "charge"
+	// EventCoupon attaches a coupon code to the ticket
+	EventCoupon JournalEventKind = "coupon"
+	// EventPayment adds a refund or an adjustment to the ledger of a closed ticket
+	EventPayment JournalEventKind = "payment"
+	// maintenance events, see DisableSlot, EnableSlot, AddFloor & RemoveFloor
+	EventSlotDisabled JournalEventKind = "slot_disabled"
+	EventSlotEnabled  JournalEventKind = "slot_enabled"
+	EventFloorAdded   JournalEventKind = "floor_added"
+	EventFloorRemoved JournalEventKind = "floor_removed"
+	// reservation events, the fulfillment is the check in carrying the reservation id
+	EventReserved             JournalEventKind = "reserved"
+	EventReservationHeld      JournalEventKind = "reservation_held"
+	EventReservationNoShow    JournalEventKind = "reservation_no_show"
+	EventReservationExpired   JournalEventKind = "reservation_expired"
+	EventReservationCancelled JournalEventKind = "reservation_cancelled"
+	// EventCompaction marks a journal folded into a snapshot, it only carries the sequence number
+	EventCompaction JournalEventKind = "compaction"
+)
+
+// JournalEvent is one line of the journal
+type JournalEvent struct {
+	Seq      uint64           `json:"seq"`
+	Kind     JournalEventKind `json:"kind"`
+	Time     int64            `json:"time"`
+	TicketId string           `json:"ticketId,omitempty"`
+	// Visit is only set on check in, see ParkingTicket.Visit
+	Visit   uint64   `json:"visit,omitempty"`
+	Vehicle *Vehicle `json:"vehicle,omitempty"`
+	FloorId int      `json:"floor"`
+	SlotId  int      `json:"slot"`
+	// SlotCount -> contiguous slots taken from SlotId on, 0 means 1
+	SlotCount      int     `json:"slotCount,omitempty"`
+	RateMultiplier float64 `json:"rateMultiplier,omitempty"`
+	// BilledAs is only set on check in, journals written before it existed bill the vehicle type
+	BilledAs *VehicleType `json:"billedAs,omitempty"`
+	Fee      *Fee         `json:"fee,omitempty"`
+	// ReservationId is set on the reservation events and on the check in of a reservation
+	ReservationId string `json:"reservationId,omitempty"`
+	// VehicleType, From & To are only set on reserved events
+	VehicleType *VehicleType `json:"vehicleType,omitempty"`
+	From        int64        `json:"from,omitempty"`
+	To          int64        `json:"to,omitempty"`
+	// EnergyKWh is only set on charge events
+	EnergyKWh float64 `json:"energyKWh,omitempty"`
+	// Coupon is only set on coupon events
+	Coupon string `json:"coupon,omitempty"`
+	// Layout is only set on floor_added events
+	Layout *FloorLayout `json:"layout,omitempty"`
+	// Transaction is the payment of a paid event, or the entry of a payment event
+	Transaction *Transaction `json:"transaction,omitempty"`
+}
+
+// Journal is an append only, file backed log of check ins, check outs & charges (one JSON event per line)
+type Journal struct {
+	mu    sync.Mutex
+	file  *os.File
+	fsync bool
+	seq   uint64
+}
+
+// OpenJournal opens (or creates) the journal at path, fsync -> sync the file after every event
+func OpenJournal(path string, fsync bool) (*Journal, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	j := &Journal{file: file, fsync: fsync}
+	// continue the sequence where the file stops
+	err = ReadJournal(file, func(ev JournalEvent) error {
+		j.seq = ev.Seq
+		return nil
+	})
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return j, nil
+}
+
+// LastSeq is the sequence number of the last appended event
+func (j *Journal) LastSeq() uint64 {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.seq
+}
+
+// Append numbers the event and writes it, the event is durable once Append returns when fsync is on
+func (j *Journal) Append(ev JournalEvent) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	ev.Seq = j.seq + 1
+	if err := j.write(ev); err != nil {
+		return err
+	}
+	j.seq = ev.Seq
+	return nil
+}
+
+func (j *Journal) write(ev JournalEvent) error {
+	line, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	if _, err := j.file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("journal write: %w", err)
+	}
+	if j.fsync {
+		if err := j.file.Sync(); err != nil {
+			return fmt.Errorf("journal sync: %w", err)
+		}
+	}
+	return nil
+}
+
+// truncate drops every event, a compaction marker keeps the sequence going after a reopen
+func (j *Journal) truncate() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if err := j.file.Truncate(0); err != nil {
+		return err
+	}
+	return j.write(JournalEvent{Seq: j.seq, Kind: EventCompaction})
+}
+
+func (j *Journal) Close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.file.Close()
+}
+
+// ReadJournal calls fn for every event of r in order
+func ReadJournal(r io.Reader, fn func(JournalEvent) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var ev JournalEvent
+		if err := json.Unmarshal(scanner.Bytes(), &ev); err != nil {
+			return fmt.Errorf("journal line %d: %w", line, err)
+		}
+		if err := fn(ev); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// WithJournal records every check in & check out of the lot in j before it takes effect
+func WithJournal(j *Journal) Option {
+	return func(pl *ParkingLot) {
+		pl.journal = j
+	}
+}
+
+func (pl *ParkingLot) journalEvent(ev JournalEvent) error {
+	if pl.journal == nil {
+		return nil
+	}
+	return pl.journal.Append(ev)
+}
+
+// Replay applies the events of r on top of the current state,
+// events already folded into the restored snapshot are skipped
+func (pl *ParkingLot) Replay(r io.Reader) error {
+	pl.lockAll()
+	defer pl.unlockAll()
+	pl.ticketLock.Lock()
+	defer pl.ticketLock.Unlock()
+
+	return ReadJournal(r, func(ev JournalEvent) error {
+		if ev.Seq <= pl.journalSeq {
+			return nil
+		}
+		if err := pl.apply(ev); err != nil {
+			return fmt.Errorf("replay event %d: %w", ev.Seq, err)
+		}
+		pl.journalSeq = ev.Seq
+		return nil
+	})
+}
+
+// apply expects every lock to be held
+func (pl *ParkingLot) apply(ev JournalEvent) error {
+	switch ev.Kind {
+	case EventCheckIn:
+		slots, err := slotRun(pl.slotStore, ev.FloorId, ev.SlotId, max(ev.SlotCount, 1))
+		if err != nil {
+			return err
+		}
+		for _, slot := range slots {
+			if slot.IsOccupied {
+				return fmt.Errorf("slot %d-%d already occupied", slot.FloorId, slot.Id)
+			}
+		}
+		if ev.Vehicle == nil {
+			return fmt.Errorf("check in without vehicle")
+		}
+		vehicle := *ev.Vehicle
+		if _, err := pl.indexVehicle(vehicle, func() string { return ev.TicketId }); err != nil {
+			return err
+		}
+		billedAs := vehicle.Type
+		if ev.BilledAs != nil {
+			billedAs = *ev.BilledAs
+		}
+		for _, slot := range slots {
+			pl.removeAvailable(slot)
+			slot.IsOccupied = true
+			slot.HeldBy = ""
+		}
+		if ev.ReservationId != "" {
+			if err := pl.fulfillReservation(ev.ReservationId, ev.TicketId); err != nil {
+				return err
+			}
+		}
+		pl.observeVisit(ev.Visit)
+		pl.ticketStore[ev.TicketId] = &ParkingTicket{
+			Id:             ev.TicketId,
+			Visit:          ev.Visit,
+			VehicleParked:  &vehicle,
+			CheckinTime:    ev.Time,
+			SlotDetails:    slots[0],
+			Slots:          slots,
+			RateMultiplier: ev.RateMultiplier,
+			BilledAs:       billedAs,
+		}
+	case EventCheckOut:
+		ticket, exists := pl.ticketStore[ev.TicketId]
+		if !exists {
+			return fmt.Errorf("%w: %s", ErrTicketNotFound, ev.TicketId)
+		}
+		if ticket.Status == TicketClosed {
+			return fmt.Errorf("%w: %s", ErrTicketClosed, ev.TicketId)
+		}
+		ticket.CheckoutTime = ev.Time
+		if ev.Fee != nil {
+			ticket.Fee = *ev.Fee
+		}
+		for _, slot := range ticket.occupiedSlots() {
+			pl.markSlotAvailable(slot)
+		}
+		ticket.Status = TicketClosed
+		pl.unindexVehicle(ticket)
+		pl.recordClosed(ticket)
+		pl.completeDrainLocked()
+	case EventPaid:
+		ticket, exists := pl.ticketStore[ev.TicketId]
+		if !exists {
+			return fmt.Errorf("%w: %s", ErrTicketNotFound, ev.TicketId)
+		}
+		if err := notActive(ticket); err != nil {
+			return err
+		}
+		ticket.PaidTime = ev.Time
+		if ev.Fee != nil {
+			ticket.Fee = *ev.Fee
+		}
+		if ev.Transaction != nil {
+			ticket.Payments = append(ticket.Payments, *ev.Transaction)
+		}
+		ticket.Status = TicketPaid
+	case EventCharge:
+		ticket, exists := pl.ticketStore[ev.TicketId]
+		if !exists {
+			return fmt.Errorf("%w: %s", ErrTicketNotFound, ev.TicketId)
+		}
+		if err := notActive(ticket); err != nil {
+			return err
+		}
+		ticket.EnergyKWh += ev.EnergyKWh
+	case EventCoupon:
+		ticket, exists := pl.ticketStore[ev.TicketId]
+		if !exists {
+			return fmt.Errorf("%w: %s", ErrTicketNotFound, ev.TicketId)
+		}
+		if err := notActive(ticket); err != nil {
+			return err
+		}
+		ticket.Coupon = ev.Coupon
+	case EventPayment:
+		ticket, exists := pl.ticketStore[ev.TicketId]
+		if !exists {
+			return fmt.Errorf("%w: %s", ErrTicketNotFound, ev.TicketId)
+		}
+		if ticket.Status == TicketActive || ev.Transaction == nil {
+			return fmt.Errorf("invalid payment event for ticket %s", ev.TicketId)
+		}
+		pl.appendLedger(ticket, *ev.Transaction)
+	case EventSlotDisabled, EventSlotEnabled:
+		slot, exists := pl.slotStore[slotKey(ev.FloorId, ev.SlotId)]
+		if !exists {
+			return fmt.Errorf("%w: %d-%d", ErrSlotNotFound, ev.FloorId, ev.SlotId)
+		}
+		if ev.Kind == EventSlotDisabled {
+			pl.disableSlot(slot)
+		} else {
+			pl.enableSlot(slot)
+		}
+	case EventFloorAdded:
+		if ev.Layout == nil {
+			return fmt.Errorf("floor added without layout")
+		}
+		if len(pl.floorSlots(ev.FloorId)) > 0 {
+			return fmt.Errorf("floor %d already exists", ev.FloorId)
+		}
+		pl.addFloor(ev.FloorId, *ev.Layout)
+	case EventFloorRemoved:
+		if len(pl.floorSlots(ev.FloorId)) == 0 {
+			return fmt.Errorf("%w: %d", ErrFloorNotFound, ev.FloorId)
+		}
+		pl.removeFloor(ev.FloorId)
+	case EventReserved, EventReservationHeld, EventReservationNoShow, EventReservationExpired, EventReservationCancelled:
+		return pl.applyReservationEvent(ev)
+	case EventCompaction:
+	default:
+		return fmt.Errorf("unknown event kind %q", ev.Kind)
+	}
+	return nil
+}
+
+// slotRun returns n consecutive slots of one floor starting at slot id
+func slotRun(slotStore map[string]*Slot, floor int, id int, n int) ([]*Slot, error) {
+	slots := make([]*Slot, 0, n)
+	for i := range n {
+		slot, exists := slotStore[slotKey(floor, id+i)]
+		if !exists {
+			return nil, fmt.Errorf("unknown slot %d-%d", floor, id+i)
+		}
+		slots = append(slots, slot)
+	}
+	return slots, nil
+}
+
+// Recover restores the snapshot at snapshotPath (if any) and replays the journal at journalPath (if any)
+func (pl *ParkingLot) Recover(snapshotPath string, journalPath string) error {
+	if err := readFileIfExists(snapshotPath, pl.Restore); err != nil {
+		return err
+	}
+	return readFileIfExists(journalPath, pl.Replay)
+}
+
+func readFileIfExists(path string, read func(io.Reader) error) error {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return read(file)
+}
+
+// CompactJournal folds the journal into a snapshot at snapshotPath and empties the journal.
+// The snapshot is written to a temp file and renamed so a crash never leaves a partial snapshot
+func (pl *ParkingLot) CompactJournal(snapshotPath string) error {
+	if pl.journal == nil {
+		return fmt.Errorf("no journal attached")
+	}
+	pl.lockAll()
+	defer pl.unlockAll()
+	pl.ticketLock.RLock()
+	defer pl.ticketLock.RUnlock()
+
+	tmp, err := os.CreateTemp(filepath.Dir(snapshotPath), filepath.Base(snapshotPath)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	enc := json.NewEncoder(tmp)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(pl.snapshotLocked()); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), snapshotPath); err != nil {
+		return err
+	}
+	return pl.journal.truncate()
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// journaledLot opens the journal at dir/journal.log and attaches it to a new 3 Car slots lot
+func journaledLot(t *testing.T, dir string, clock Clock) (*ParkingLot, *Journal) {
+	t.Helper()
+	journal, err := OpenJournal(filepath.Join(dir, "journal.log"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { journal.Close() })
+	lot, err := NewParkingLot(Layout{Floors: []FloorLayout{UniformFloor(Car, 3)}}, &NormalPricing{Clock: clock}, WithClock(clock), WithJournal(journal))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return lot, journal
+}
+
+func TestJournalRecoverReplaysEvents(t *testing.T) {
+	dir := t.TempDir()
+	clock := NewFakeClock(time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC))
+	lot, journal := journaledLot(t, dir, clock)
+	first, err := lot.CheckIn(Vehicle{RegistrationNumber: "KA-01-0001", Type: Car})
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := lot.CheckIn(Vehicle{RegistrationNumber: "KA-01-0002", Type: Car})
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(time.Hour)
+	if _, err := lot.Unpark(first.Id); err != nil {
+		t.Fatal(err)
+	}
+	if journal.LastSeq() != 3 {
+		t.Fatalf("LastSeq() = %d, want 3", journal.LastSeq())
+	}
+
+	recovered, err := NewParkingLot(Layout{Floors: []FloorLayout{UniformFloor(Car, 3)}}, &NormalPricing{Clock: clock}, WithClock(clock))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := recovered.Recover(filepath.Join(dir, "missing.json"), filepath.Join(dir, "journal.log")); err != nil {
+		t.Fatal(err)
+	}
+	if got := recovered.FreeSlotCount(Car); got != 2 {
+		t.Fatalf("FreeSlotCount(Car) = %d after recovery, want 2", got)
+	}
+	if _, err := recovered.Unpark(first.Id); !errors.Is(err, ErrTicketClosed) {
+		t.Fatalf("Unpark of the replayed check out err = %v, want ErrTicketClosed", err)
+	}
+	if _, err := recovered.Unpark(second.Id); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCompactJournal(t *testing.T) {
+	dir := t.TempDir()
+	snapshotPath := filepath.Join(dir, "snapshot.json")
+	clock := NewFakeClock(time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC))
+	lot, journal := journaledLot(t, dir, clock)
+	if _, err := lot.CheckIn(Vehicle{RegistrationNumber: "KA-01-0001", Type: Car}); err != nil {
+		t.Fatal(err)
+	}
+	if err := lot.CompactJournal(snapshotPath); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := lot.CheckIn(Vehicle{RegistrationNumber: "KA-01-0002", Type: Car}); err != nil {
+		t.Fatal(err)
+	}
+	journal.Close()
+
+	// the sequence goes on after the compaction marker
+	reopened, err := OpenJournal(filepath.Join(dir, "journal.log"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	if reopened.LastSeq() != 2 {
+		t.Fatalf("LastSeq() after reopen = %d, want 2", reopened.LastSeq())
+	}
+
+	// snapshot + the one event written after it, nothing applied twice
+	recovered, err := NewParkingLot(Layout{Floors: []FloorLayout{UniformFloor(Car, 3)}}, &NormalPricing{Clock: clock}, WithClock(clock))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := recovered.Recover(snapshotPath, filepath.Join(dir, "journal.log")); err != nil {
+		t.Fatal(err)
+	}
+	if got := recovered.FreeSlotCount(Car); got != 1 {
+		t.Fatalf("FreeSlotCount(Car) after recovery = %d, want 1", got)
+	}
+	if matches, _ := filepath.Glob(snapshotPath + ".tmp-*"); len(matches) != 0 {
+		t.Fatalf("temp snapshots left behind: %v", matches)
+	}
+}
+
+func TestCompactJournalNeedsJournal(t *testing.T) {
+	lot, err := NewParkingLot(Layout{Floors: []FloorLayout{UniformFloor(Car, 1)}}, &NormalPricing{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "snapshot.json")
+	if err := lot.CompactJournal(path); err == nil {
+		t.Fatal("CompactJournal without journal succeeded")
+	}
+	if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("snapshot written without journal: %v", err)
+	}
+}
+
+// newJournaledLot opens the journal at path and replays what it already holds
+func newJournaledLot(t *testing.T, clock *FakeClock, path string) *ParkingLot {
+	t.Helper()
+	journal, err := OpenJournal(path, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { journal.Close() })
+	lot, err := NewParkingLot(Layout{Floors: []FloorLayout{UniformFloor(Car, 2)}}, &NormalPricing{Clock: clock},
+		WithID("PR123"), WithClock(clock), WithJournal(journal))
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if err := lot.Replay(file); err != nil {
+		t.Fatal(err)
+	}
+	return lot
+}
+
+func TestReplayReusedTicketID(t *testing.T) {
+	clock := NewFakeClock(time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC))
+	path := filepath.Join(t.TempDir(), "journal.log")
+	lot := newJournaledLot(t, clock, path)
+	for _, registration := range []string{"KA-01-0001", "KA-01-0002"} {
+		ticket, err := lot.CheckIn(Vehicle{RegistrationNumber: registration, Type: Car})
+		if err != nil {
+			t.Fatal(err)
+		}
+		clock.Advance(time.Hour)
+		if _, err := lot.Unpark(ticket.Id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	parked, err := lot.CheckIn(Vehicle{RegistrationNumber: "KA-01-0003", Type: Car})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	replayed := newJournaledLot(t, clock, path)
+	got, err := replayed.GetTicket(parked.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Visit != parked.Visit || got.Status != TicketActive {
+		t.Fatalf("replayed ticket %+v, want visit %d active", got, parked.Visit)
+	}
+	if history := replayed.History(time.Time{}, clock.Now().Add(time.Second)); len(history) != 2 {
+		t.Fatalf("%d tickets in the replayed history, want 2", len(history))
+	}
+}
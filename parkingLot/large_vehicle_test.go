@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestContiguousStarts(t *testing.T) {
+	// floor 0 : 0 1 2 _ 4, floor 1 : 0 1
+	var free []*Slot
+	for _, key := range [][2]int{{0, 0}, {0, 1}, {0, 2}, {0, 4}, {1, 0}, {1, 1}} {
+		free = append(free, &Slot{FloorId: key[0], Id: key[1], Type: Truck})
+	}
+	tests := []struct {
+		need int
+		want string
+	}{
+		{1, "0-0 0-1 0-2 0-4 1-0 1-1"},
+		{2, "0-0 0-1 1-0"},
+		{3, "0-0"},
+		{4, ""},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, slot := range contiguousStarts(free, tt.need) {
+			got = append(got, slotKey(slot.FloorId, slot.Id))
+		}
+		if strings.Join(got, " ") != tt.want {
+			t.Errorf("contiguousStarts(%d) = %v, want [%s]", tt.need, got, tt.want)
+		}
+	}
+}
+
+func TestBusTakesAdjacentTruckSlots(t *testing.T) {
+	clock := NewFakeClock(time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC))
+	layout := Layout{Floors: []FloorLayout{UniformFloor(Truck, 3), UniformFloor(Truck, 2)}}
+	lot, err := NewParkingLot(layout, &NormalPricing{Clock: clock}, WithClock(clock))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// two trucks leave a single free slot on the first floor, the bus goes to the second one
+	if _, err := lot.CheckIn(Vehicle{RegistrationNumber: "KA-01-0001", Type: Truck}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := lot.CheckIn(Vehicle{RegistrationNumber: "KA-01-0002", Type: Truck}); err != nil {
+		t.Fatal(err)
+	}
+	bus, err := lot.CheckIn(Vehicle{RegistrationNumber: "KA-01-0003", Type: Bus})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bus.Slots) != 2 || bus.SlotDetails.FloorId != 1 || bus.Slots[0].Id != 0 || bus.Slots[1].Id != 1 {
+		t.Fatalf("bus parked in %+v", bus.Slots)
+	}
+	if _, err := lot.CheckIn(Vehicle{RegistrationNumber: "KA-01-0004", Type: Bus}); !errors.Is(err, ErrNoSlotAvailable) {
+		t.Fatalf("second bus err = %v, want ErrNoSlotAvailable", err)
+	}
+	if _, err := lot.CheckIn(Vehicle{RegistrationNumber: "KA-01-0005", Type: Trailer}); !errors.Is(err, ErrNoSlotAvailable) {
+		t.Fatalf("trailer err = %v, want ErrNoSlotAvailable", err)
+	}
+
+	// a snapshot keeps both slots of the bus
+	var snap bytes.Buffer
+	if err := lot.Snapshot(&snap); err != nil {
+		t.Fatal(err)
+	}
+	restored, err := NewParkingLot(layout, &NormalPricing{Clock: clock}, WithClock(clock))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := restored.Restore(&snap); err != nil {
+		t.Fatal(err)
+	}
+
+	clock.Advance(2 * time.Hour)
+	for _, pl := range []*ParkingLot{lot, restored} {
+		receipt, err := pl.Unpark(bus.Id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fmt.Sprint(receipt.SlotIds) != "[0 1]" || receipt.Fee.Total != 2*Bus.BasePrice() {
+			t.Fatalf("receipt %+v", receipt)
+		}
+		if got := pl.FreeSlotCount(Truck); got != 3 {
+			t.Fatalf("FreeSlotCount(Truck) = %d after the bus left, want 3", got)
+		}
+	}
+}
+
+func TestLayoutRejectsLargeVehicleSlots(t *testing.T) {
+	layout := Layout{Floors: []FloorLayout{{Slots: []SlotSpec{{Type: Truck}, {Type: Bus}}}}}
+	if err := layout.Validate(); err == nil {
+		t.Fatal("a Bus slot passed validation")
+	}
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"gopkg.in/yaml.v3"
+)
+
+// SlotSpec describes a single slot in a floor layout.
+// In JSON & YAML a slot can be written either as a bare type ("Car") or as an object ({"type": "Car", "distance": 40, "charger": true}).
+type SlotSpec struct {
+	Type VehicleType `json:"type" yaml:"type"`
+	// Distance from the entry gate, used by NearestToGate
+	Distance int `json:"distance,omitempty" yaml:"distance,omitempty"`
+	// Charger -> the slot has an EV charger
+	Charger bool `json:"charger,omitempty" yaml:"charger,omitempty"`
+}
+
+func (s *SlotSpec) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err == nil {
+		return s.Type.UnmarshalText([]byte(name))
+	}
+	type plain SlotSpec
+	var p plain
+	if err := json.Unmarshal(data, &p); err != nil {
+		return err
+	}
+	*s = SlotSpec(p)
+	return nil
+}
+
+func (s *SlotSpec) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		return s.Type.UnmarshalText([]byte(node.Value))
+	}
+	var p struct {
+		Type     string `yaml:"type"`
+		Distance int    `yaml:"distance"`
+		Charger  bool   `yaml:"charger"`
+	}
+	if err := node.Decode(&p); err != nil {
+		return err
+	}
+	*s = SlotSpec{Distance: p.Distance, Charger: p.Charger}
+	return s.Type.UnmarshalText([]byte(p.Type))
+}
+
+// FloorLayout lists the slots of one floor, slot id = position in the list
+type FloorLayout struct {
+	Slots []SlotSpec `json:"slots" yaml:"slots"`
+}
+
+// Layout describes the whole lot, floor number = position in the list
+type Layout struct {
+	Floors []FloorLayout `json:"floors" yaml:"floors"`
+}
+
+// DefaultLayout builds floors with the legacy assignment : 1 -> Truck, 2-4 -> Bike, everything else -> Car
+func DefaultLayout(floors int, slots int) Layout {
+	layout := Layout{Floors: make([]FloorLayout, floors)}
+	for i := range floors {
+		specs := make([]SlotSpec, slots)
+		for j := range slots {
+			switch {
+			case j == 1:
+				specs[j].Type = Truck
+			case j > 1 && j <= 4:
+				specs[j].Type = Bike
+			default:
+				specs[j].Type = Car
+			}
+		}
+		layout.Floors[i].Slots = specs
+	}
+	return layout
+}
+
+// UniformFloor returns a floor with n slots of the same type, e.g. a truck-only floor
+func UniformFloor(vtype VehicleType, n int) FloorLayout {
+	specs := make([]SlotSpec, n)
+	for i := range specs {
+		specs[i].Type = vtype
+	}
+	return FloorLayout{Slots: specs}
+}
+
+// Validate checks that the layout has at least one slot and only known vehicle types
+func (l Layout) Validate() error {
+	total := 0
+	for f, floor := range l.Floors {
+		for s, spec := range floor.Slots {
+			if !slices.Contains(slotTypes, spec.Type) {
+				return fmt.Errorf("floor %d slot %d: %q is not a slot type", f, s, spec.Type.ToString())
+			}
+			total++
+		}
+	}
+	if total == 0 {
+		return fmt.Errorf("layout has no slots")
+	}
+	return nil
+}
+
+// ParseLayout reads a JSON layout, e.g.
+//
+//	{"floors": [{"slots": ["Truck", "Truck"]}, {"slots": ["Bike", "Car", {"type": "Car", "distance": 40}]}]}
+func ParseLayout(r io.Reader) (Layout, error) {
+	var layout Layout
+	if err := json.NewDecoder(r).Decode(&layout); err != nil {
+		return Layout{}, fmt.Errorf("invalid layout: %w", err)
+	}
+	if err := layout.Validate(); err != nil {
+		return Layout{}, err
+	}
+	return layout, nil
+}
+
+// ParseLayoutYAML reads a YAML layout, e.g.
+//
+//	floors:
+//	  - slots: [Truck, Truck]
+//	  - slots: [Bike, Car, {type: Car, distance: 40, charger: true}]
+func ParseLayoutYAML(r io.Reader) (Layout, error) {
+	var layout Layout
+	if err := yaml.NewDecoder(r).Decode(&layout); err != nil {
+		return Layout{}, fmt.Errorf("invalid layout: %w", err)
+	}
+	if err := layout.Validate(); err != nil {
+		return Layout{}, err
+	}
+	return layout, nil
+}
+
+// LoadLayout reads a layout from a file, YAML for .yaml & .yml files, JSON otherwise
+func LoadLayout(path string) (Layout, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Layout{}, err
+	}
+	defer f.Close()
+	switch filepath.Ext(path) {
+	case ".yaml", ".yml":
+		return ParseLayoutYAML(f)
+	}
+	return ParseLayout(f)
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseLayoutJSON(t *testing.T) {
+	layout, err := ParseLayout(strings.NewReader(`{"floors": [{"slots": ["Truck", "Truck"]}, {"slots": ["Bike", "Car", {"type": "car"}]}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lot, err := NewParkingLot(layout, &NormalPricing{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// floor & slot ids are positions in the layout, the pools are sorted by floor then slot
+	want := map[VehicleType][][2]int{
+		Truck: {{0, 0}, {0, 1}},
+		Bike:  {{1, 0}},
+		Car:   {{1, 1}, {1, 2}},
+	}
+	for vtype, slots := range want {
+		got := lot.GetAvailableSlots(vtype)
+		if len(got) != len(slots) {
+			t.Fatalf("%s slots = %d, want %d", vtype.ToString(), len(got), len(slots))
+		}
+		for i, slot := range got {
+			if slot.FloorId != slots[i][0] || slot.Id != slots[i][1] || slot.Type != vtype {
+				t.Errorf("%s slot %d = %d-%d %s, want %d-%d", vtype.ToString(), i, slot.FloorId, slot.Id, slot.Type.ToString(), slots[i][0], slots[i][1])
+			}
+		}
+	}
+}
+
+func TestLayoutValidate(t *testing.T) {
+	for name, input := range map[string]string{
+		"no floors":    `{"floors": []}`,
+		"empty floors": `{"floors": [{"slots": []}]}`,
+		"unknown type": `{"floors": [{"slots": ["Car", "Boat"]}]}`,
+		"not json":     `floors: 2`,
+	} {
+		if _, err := ParseLayout(strings.NewReader(input)); err == nil {
+			t.Errorf("%s: ParseLayout accepted %s", name, input)
+		}
+	}
+	if _, err := NewParkingLot(Layout{}, &NormalPricing{}); err == nil {
+		t.Errorf("NewParkingLot accepted an empty layout")
+	}
+}
+
+func TestDefaultLayout(t *testing.T) {
+	layout := DefaultLayout(2, 6)
+	if len(layout.Floors) != 2 {
+		t.Fatalf("floors = %d, want 2", len(layout.Floors))
+	}
+	want := []VehicleType{Car, Truck, Bike, Bike, Bike, Car}
+	for f, floor := range layout.Floors {
+		for s, spec := range floor.Slots {
+			if spec.Type != want[s] {
+				t.Errorf("floor %d slot %d = %s, want %s", f, s, spec.Type.ToString(), want[s].ToString())
+			}
+		}
+	}
+	if floor := UniformFloor(Truck, 3); len(floor.Slots) != 3 || floor.Slots[2].Type != Truck {
+		t.Errorf("UniformFloor(Truck, 3) = %+v", floor)
+	}
+}
+
+func TestParseLayoutYAMLMatchesJSON(t *testing.T) {
+	fromJSON, err := ParseLayout(strings.NewReader(`{"floors": [
+		{"slots": ["Truck", "Truck"]},
+		{"slots": ["Bike", "Car", {"type": "Car", "distance": 40, "charger": true}]}
+	]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fromYAML, err := ParseLayoutYAML(strings.NewReader(`
+floors:
+  - slots: [Truck, Truck]
+  - slots:
+      - Bike
+      - Car
+      - {type: Car, distance: 40, charger: true}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(fromJSON, fromYAML) {
+		t.Fatalf("yaml layout %+v, json layout %+v", fromYAML, fromJSON)
+	}
+}
+
+func TestParseLayoutYAMLRejectsUnknownType(t *testing.T) {
+	if _, err := ParseLayoutYAML(strings.NewReader("floors:\n  - slots: [Boat]\n")); err == nil {
+		t.Fatal("expected an error for an unknown slot type")
+	}
+	if _, err := ParseLayoutYAML(strings.NewReader("floors: []\n")); err == nil {
+		t.Fatal("expected an error for a layout without slots")
+	}
+}
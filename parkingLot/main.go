@@ -1,26 +1,87 @@
 package main
 
 import (
-	"fmt"
+	"flag"
+	"log"
+	"net/http"
+	"os"
 	"time"
 )
 
+// manualClockStart is where the manual clock starts so runs are reproducible
+var manualClockStart = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
 
 func main() {
-	fmt.Println("Parking lot")
-	var pricing NormalPricing
-	pl := NewParkingLot(2, 8, &pricing)
+	httpAddr := flag.String("http", "", "serve the REST API on this address, e.g. :8080")
+	lotID := flag.String("id", DefaultLotID, "lot id used in the ticket ids of the REST API")
+	layoutPath := flag.String("layout", "", "JSON or YAML (.yaml/.yml) layout file for the REST API & the simulation, default is 2 floors x 8 slots")
+	kWhPrice := flag.Int("kwh-price", 8, "energy tariff of the REST API charger slots, per kWh")
+	simulate := flag.Duration("simulate", 0, "simulate this much traffic on the layout and print the report, e.g. 24h")
+	gates := flag.Int("gates", 2, "simulation: entry gates, and as many exit gates")
+	arrival := flag.Duration("arrival", 10*time.Minute, "simulation: mean gap between two vehicles at an entry gate")
+	stay := flag.Duration("stay", 2*time.Hour, "simulation: mean stay, uniform from 0 to twice this")
+	seed := flag.Uint64("seed", 1, "simulation: traffic seed")
+	manualClock := flag.Bool("manual-clock", false, "CLI only: start the clock at "+manualClockStart.Format(time.RFC3339)+" and move it with `advance`")
+	flag.Parse()
 
-	ptck, err := pl.CheckIn(Vehicle{
-		RegistrationNumber: "1",
-		Type: Car,
-	})
+	layout := DefaultLayout(2, 8)
+	if *layoutPath != "" {
+		var err error
+		if layout, err = LoadLayout(*layoutPath); err != nil {
+			log.Fatal(err)
+		}
+	}
 
-	fmt.Println(ptck, err)
+	if *simulate > 0 {
+		clock := NewFakeClock(manualClockStart)
+		pl, err := NewParkingLot(layout, &NormalPricing{Clock: clock}, WithClock(clock), WithID(*lotID))
+		if err != nil {
+			log.Fatal(err)
+		}
+		report, err := Simulate(pl, clock, SimulationConfig{
+			EntryGates: *gates,
+			ExitGates:  *gates,
+			Duration:   *simulate,
+			Arrival:    Exponential{Mean: *arrival},
+			Stay:       Uniform{Max: 2 * *stay},
+			VehicleMix: map[VehicleType]int{Bike: 3, Car: 5, Truck: 1, ElectricCar: 1},
+			Seed:       *seed,
+		})
+		if err != nil {
+			log.Fatal(err)
+		}
+		report.Print(os.Stdout)
+		if len(report.Violations) > 0 {
+			os.Exit(1)
+		}
+		return
+	}
 
-	time.Sleep(3 * time.Second)
+	if *httpAddr != "" {
+		// card & wallet payments go to an in-memory gateway until a real one is plugged in
+		pl, err := NewParkingLot(layout, &ChargingPricing{Base: &NormalPricing{}, PricePerKWh: *kWhPrice}, WithID(*lotID), WithPaymentProcessor(NewFakeGateway()))
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("serving parking lot on %s", *httpAddr)
+		log.Fatal(http.ListenAndServe(*httpAddr, NewServer(pl)))
+	}
 
-	price := pl.Checkout(ptck)
-
-	fmt.Println("Price", price)
-}
\ No newline at end of file
+	// commands come from the file given as argument, or from stdin
+	var clock Clock = SystemClock
+	if *manualClock {
+		clock = NewFakeClock(manualClockStart)
+	}
+	in := os.Stdin
+	if flag.NArg() > 0 {
+		file, err := os.Open(flag.Arg(0))
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer file.Close()
+		in = file
+	}
+	if err := NewCLI(os.Stdout, clock).Run(in); err != nil {
+		log.Fatal(err)
+	}
+}
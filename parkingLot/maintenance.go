@@ -0,0 +1,221 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+var (
+	ErrSlotNotFound  = errors.New("slot not found")
+	ErrFloorNotFound = errors.New("floor not found")
+	ErrSlotOccupied  = errors.New("slot occupied")
+	ErrLastFloor     = errors.New("last floor of the lot")
+	ErrFloorDraining = errors.New("floor is being removed")
+)
+
+type RemovalMode int
+
+const (
+	// RefuseOccupied -> the slot or floor must be empty
+	RefuseOccupied RemovalMode = iota
+	// Drain -> no new vehicle is given the slots, the change completes once the vehicles inside have left
+	Drain
+)
+
+// DisableSlot closes a slot for maintenance, it is taken out of the availability index and the capacity.
+// With Drain an occupied slot is disabled right away and not given out again once freed
+func (pl *ParkingLot) DisableSlot(floor int, id int, mode RemovalMode) error {
+	pl.lockAll()
+	defer pl.unlockAll()
+	slot, exists := pl.slotStore[slotKey(floor, id)]
+	if !exists {
+		return fmt.Errorf("%w: %d-%d", ErrSlotNotFound, floor, id)
+	}
+	if slot.IsOccupied && mode == RefuseOccupied {
+		return fmt.Errorf("%w: %d-%d", ErrSlotOccupied, floor, id)
+	}
+	if slot.Disabled {
+		return nil
+	}
+
+	err := pl.journalEvent(JournalEvent{Kind: EventSlotDisabled, Time: pl.clock.Now().UnixNano(), FloorId: floor, SlotId: id})
+	if err != nil {
+		return err
+	}
+	pl.disableSlot(slot)
+	return nil
+}
+
+// EnableSlot puts a disabled slot back in service, a slot still draining simply stops draining
+func (pl *ParkingLot) EnableSlot(floor int, id int) error {
+	pl.lockAll()
+	defer pl.unlockAll()
+	slot, exists := pl.slotStore[slotKey(floor, id)]
+	if !exists {
+		return fmt.Errorf("%w: %d-%d", ErrSlotNotFound, floor, id)
+	}
+	if !slot.Disabled {
+		return nil
+	}
+	if pl.drainingFloors[floor] {
+		return fmt.Errorf("%w: %d", ErrFloorDraining, floor)
+	}
+
+	err := pl.journalEvent(JournalEvent{Kind: EventSlotEnabled, Time: pl.clock.Now().UnixNano(), FloorId: floor, SlotId: id})
+	if err != nil {
+		return err
+	}
+	pl.enableSlot(slot)
+	return nil
+}
+
+// AddFloor opens a new floor above the highest one and returns its floor id
+func (pl *ParkingLot) AddFloor(floor FloorLayout) (int, error) {
+	if err := (Layout{Floors: []FloorLayout{floor}}).Validate(); err != nil {
+		return 0, err
+	}
+	pl.lockAll()
+	defer pl.unlockAll()
+
+	floorID := 0
+	for _, slot := range pl.slotStore {
+		floorID = max(floorID, slot.FloorId+1)
+	}
+	err := pl.journalEvent(JournalEvent{Kind: EventFloorAdded, Time: pl.clock.Now().UnixNano(), FloorId: floorID, Layout: &floor})
+	if err != nil {
+		return 0, err
+	}
+	pl.addFloor(floorID, floor)
+	return floorID, nil
+}
+
+// RemoveFloor takes a floor out of the lot. With Drain an occupied floor is disabled right away
+// and removed at the checkout of its last vehicle
+func (pl *ParkingLot) RemoveFloor(floor int, mode RemovalMode) error {
+	pl.lockAll()
+	defer pl.unlockAll()
+	slots := pl.floorSlots(floor)
+	if len(slots) == 0 {
+		return fmt.Errorf("%w: %d", ErrFloorNotFound, floor)
+	}
+	if len(slots) == len(pl.slotStore) {
+		return fmt.Errorf("%w: %d", ErrLastFloor, floor)
+	}
+	if mode == RefuseOccupied {
+		for _, slot := range slots {
+			if slot.IsOccupied {
+				return fmt.Errorf("%w: %d-%d", ErrSlotOccupied, slot.FloorId, slot.Id)
+			}
+		}
+	}
+
+	err := pl.journalEvent(JournalEvent{Kind: EventFloorRemoved, Time: pl.clock.Now().UnixNano(), FloorId: floor})
+	if err != nil {
+		return err
+	}
+	pl.removeFloor(floor)
+	return nil
+}
+
+// DrainingFloors returns the floors waiting for their last vehicles before being removed
+func (pl *ParkingLot) DrainingFloors() []int {
+	pl.lockAll()
+	defer pl.unlockAll()
+	floors := make([]int, 0, len(pl.drainingFloors))
+	for floor := range pl.drainingFloors {
+		floors = append(floors, floor)
+	}
+	slices.Sort(floors)
+	return floors
+}
+
+// the helpers below are shared with the journal replay, every pool lock has to be held
+
+func (pl *ParkingLot) disableSlot(slot *Slot) {
+	if slot.Disabled {
+		return
+	}
+	pl.releaseHeldSlot(slot)
+	pl.removeAvailable(slot)
+	slot.Disabled = true
+	pl.slotCount[slot.Type]--
+	pl.publishSlot(SlotDisabled, slot)
+}
+
+func (pl *ParkingLot) enableSlot(slot *Slot) {
+	if !slot.Disabled {
+		return
+	}
+	slot.Disabled = false
+	pl.slotCount[slot.Type]++
+	pl.publishSlot(SlotEnabled, slot)
+	if !slot.IsOccupied {
+		pl.markSlotAvailable(slot)
+	}
+}
+
+// releaseHeldSlot sends the reservation holding slot back to pending, it gets another slot on the next run,
+// a waiter holding it goes back to waiting
+func (pl *ParkingLot) releaseHeldSlot(slot *Slot) {
+	if slot.HeldBy == "" || pl.dropWaitlistHold(slot) {
+		return
+	}
+	pl.reservationLock.Lock()
+	defer pl.reservationLock.Unlock()
+	if reservation, exists := pl.reservations[slot.HeldBy]; exists && reservation.Slot == slot {
+		reservation.Slot = nil
+		reservation.Status = ReservationPending
+	}
+	slot.HeldBy = ""
+}
+
+func (pl *ParkingLot) addFloor(floorID int, floor FloorLayout) {
+	for j, spec := range floor.Slots {
+		slot := &Slot{Id: j, FloorId: floorID, Type: spec.Type, Distance: spec.Distance, Charger: spec.Charger}
+		pl.slotStore[slotKey(floorID, j)] = slot
+		pl.slotCount[slot.Type]++
+		pl.markSlotAvailable(slot)
+	}
+}
+
+func (pl *ParkingLot) removeFloor(floor int) {
+	for _, slot := range pl.floorSlots(floor) {
+		pl.disableSlot(slot)
+	}
+	pl.drainingFloors[floor] = true
+	pl.completeDrainLocked()
+}
+
+// floorSlots returns the slots of floor, sorted by id
+func (pl *ParkingLot) floorSlots(floor int) []*Slot {
+	slots := make([]*Slot, 0)
+	for _, slot := range pl.slotStore {
+		if slot.FloorId == floor {
+			slots = append(slots, slot)
+		}
+	}
+	slices.SortFunc(slots, compareSlot)
+	return slots
+}
+
+// completeDrain removes the draining floors left empty, Unpark runs it once the pool lock is released
+func (pl *ParkingLot) completeDrain() {
+	pl.lockAll()
+	defer pl.unlockAll()
+	pl.completeDrainLocked()
+}
+
+func (pl *ParkingLot) completeDrainLocked() {
+	for floor := range pl.drainingFloors {
+		slots := pl.floorSlots(floor)
+		if slices.ContainsFunc(slots, func(slot *Slot) bool { return slot.IsOccupied }) {
+			continue
+		}
+		// tickets keep pointing at the removed slots, the store no longer does
+		for _, slot := range slots {
+			delete(pl.slotStore, slotKey(slot.FloorId, slot.Id))
+		}
+		delete(pl.drainingFloors, floor)
+	}
+}
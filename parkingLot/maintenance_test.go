@@ -0,0 +1,139 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestDisableAndEnableSlot(t *testing.T) {
+	lot, err := NewParkingLot(Layout{Floors: []FloorLayout{UniformFloor(Car, 2)}}, &NormalPricing{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ticket, err := lot.CheckIn(Vehicle{RegistrationNumber: "KA-01-0001", Type: Car})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := lot.DisableSlot(0, 0, RefuseOccupied); !errors.Is(err, ErrSlotOccupied) {
+		t.Fatalf("DisableSlot of the occupied slot err = %v, want ErrSlotOccupied", err)
+	}
+	if err := lot.DisableSlot(0, 9, RefuseOccupied); !errors.Is(err, ErrSlotNotFound) {
+		t.Fatalf("DisableSlot of an unknown slot err = %v, want ErrSlotNotFound", err)
+	}
+	if err := lot.DisableSlot(0, 1, RefuseOccupied); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := lot.CheckIn(Vehicle{RegistrationNumber: "KA-01-0002", Type: Car}); !errors.Is(err, ErrNoSlotAvailable) {
+		t.Fatalf("check in with the free slot disabled err = %v, want ErrNoSlotAvailable", err)
+	}
+
+	// a drained slot is not given out again once its vehicle left
+	if err := lot.DisableSlot(0, 0, Drain); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := lot.Unpark(ticket.Id); err != nil {
+		t.Fatal(err)
+	}
+	if free := lot.FreeSlotCount(Car); free != 0 {
+		t.Fatalf("%d free slots with both slots disabled, want 0", free)
+	}
+
+	if err := lot.EnableSlot(0, 1); err != nil {
+		t.Fatal(err)
+	}
+	if free := lot.FreeSlotCount(Car); free != 1 {
+		t.Fatalf("%d free slots after EnableSlot, want 1", free)
+	}
+}
+
+func TestAddFloor(t *testing.T) {
+	lot, err := NewParkingLot(Layout{Floors: []FloorLayout{UniformFloor(Car, 1)}}, &NormalPricing{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := lot.AddFloor(FloorLayout{Slots: []SlotSpec{{Type: Bus}}}); err == nil {
+		t.Fatal("AddFloor accepted a Bus slot")
+	}
+	floor, err := lot.AddFloor(UniformFloor(Truck, 2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if floor != 1 {
+		t.Fatalf("new floor id %d, want 1", floor)
+	}
+	bus, err := lot.CheckIn(Vehicle{RegistrationNumber: "KA-01-0001", Type: Bus})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bus.SlotDetails.FloorId != 1 || len(bus.Slots) != 2 {
+		t.Fatalf("bus parked in %+v", bus.Slots)
+	}
+}
+
+func TestRemoveFloorDrains(t *testing.T) {
+	lot, err := NewParkingLot(Layout{Floors: []FloorLayout{UniformFloor(Car, 2), UniformFloor(Car, 2)}}, &NormalPricing{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ticket, err := lot.CheckIn(Vehicle{RegistrationNumber: "KA-01-0001", Type: Car})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := lot.RemoveFloor(0, RefuseOccupied); !errors.Is(err, ErrSlotOccupied) {
+		t.Fatalf("RemoveFloor of an occupied floor err = %v, want ErrSlotOccupied", err)
+	}
+	if err := lot.RemoveFloor(5, Drain); !errors.Is(err, ErrFloorNotFound) {
+		t.Fatalf("RemoveFloor of an unknown floor err = %v, want ErrFloorNotFound", err)
+	}
+
+	if err := lot.RemoveFloor(0, Drain); err != nil {
+		t.Fatal(err)
+	}
+	if draining := lot.DrainingFloors(); fmt.Sprint(draining) != "[0]" {
+		t.Fatalf("draining floors %v, want [0]", draining)
+	}
+	if free := lot.FreeSlotCount(Car); free != 2 {
+		t.Fatalf("%d free slots while floor 0 drains, want 2", free)
+	}
+	if err := lot.EnableSlot(0, 1); !errors.Is(err, ErrFloorDraining) {
+		t.Fatalf("EnableSlot on a draining floor err = %v, want ErrFloorDraining", err)
+	}
+
+	// the last vehicle leaving completes the removal
+	if _, err := lot.Unpark(ticket.Id); err != nil {
+		t.Fatal(err)
+	}
+	if draining := lot.DrainingFloors(); len(draining) != 0 {
+		t.Fatalf("draining floors %v after the last vehicle left, want none", draining)
+	}
+	if err := lot.DisableSlot(0, 0, RefuseOccupied); !errors.Is(err, ErrSlotNotFound) {
+		t.Fatalf("slot of the removed floor err = %v, want ErrSlotNotFound", err)
+	}
+	if err := lot.RemoveFloor(1, RefuseOccupied); !errors.Is(err, ErrLastFloor) {
+		t.Fatalf("RemoveFloor of the last floor err = %v, want ErrLastFloor", err)
+	}
+}
+
+func TestCompleteDrainKeepsOccupiedFloors(t *testing.T) {
+	lot, err := NewParkingLot(Layout{Floors: []FloorLayout{UniformFloor(Car, 1), UniformFloor(Car, 1), UniformFloor(Car, 1)}}, &NormalPricing{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := lot.CheckIn(Vehicle{RegistrationNumber: "KA-01-0001", Type: Car}); err != nil {
+		t.Fatal(err)
+	}
+	for _, floor := range []int{0, 1} {
+		if err := lot.RemoveFloor(floor, Drain); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// floor 1 was empty and went right away, floor 0 waits for its car
+	lot.completeDrain()
+	if draining := lot.DrainingFloors(); fmt.Sprint(draining) != "[0]" {
+		t.Fatalf("draining floors %v, want [0]", draining)
+	}
+	if floor, err := lot.AddFloor(UniformFloor(Car, 1)); err != nil || floor != 3 {
+		t.Fatalf("AddFloor = %d, %v, want floor 3", floor, err)
+	}
+}
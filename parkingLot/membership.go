@@ -0,0 +1,214 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrInvalidPass   = errors.New("invalid pass")
+	ErrInvalidCoupon = errors.New("invalid coupon")
+)
+
+// Pass is a membership, e.g. a monthly commuter pass, valid in [ValidFrom, ValidTo)
+type Pass struct {
+	RegistrationNumber string
+	VehicleType        VehicleType
+	ValidFrom          time.Time
+	ValidTo            time.Time
+	// Lots the pass is valid in, empty means every lot
+	Lots []string
+}
+
+// covers -> the pass applies to vehicle entering lotID at t
+func (p Pass) covers(vehicle Vehicle, lotID string, t time.Time) bool {
+	return p.RegistrationNumber == vehicle.RegistrationNumber &&
+		p.VehicleType == vehicle.Type &&
+		!t.Before(p.ValidFrom) && t.Before(p.ValidTo) &&
+		(len(p.Lots) == 0 || slices.Contains(p.Lots, lotID))
+}
+
+// MembershipRegistry holds the passes by registration number, it can be shared by several lots
+type MembershipRegistry struct {
+	mu     sync.RWMutex
+	passes map[string][]Pass
+}
+
+func NewMembershipRegistry() *MembershipRegistry {
+	return &MembershipRegistry{passes: make(map[string][]Pass)}
+}
+
+// Add stores pass, a vehicle may hold several passes e.g. this month's and the next one
+func (m *MembershipRegistry) Add(pass Pass) error {
+	if pass.RegistrationNumber == "" {
+		return fmt.Errorf("%w: registration number is required", ErrInvalidPass)
+	}
+	if !pass.ValidFrom.Before(pass.ValidTo) {
+		return fmt.Errorf("%w: validity %v - %v", ErrInvalidPass, pass.ValidFrom, pass.ValidTo)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	pass.Lots = slices.Clone(pass.Lots)
+	m.passes[pass.RegistrationNumber] = append(m.passes[pass.RegistrationNumber], pass)
+	return nil
+}
+
+// Remove drops every pass of the vehicle
+func (m *MembershipRegistry) Remove(registrationNumber string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.passes, registrationNumber)
+}
+
+// Passes returns the passes of the vehicle, expired ones included
+func (m *MembershipRegistry) Passes(registrationNumber string) []Pass {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return slices.Clone(m.passes[registrationNumber])
+}
+
+// ValidPass returns the pass covering vehicle in lotID at t, if any
+func (m *MembershipRegistry) ValidPass(vehicle Vehicle, lotID string, t time.Time) (Pass, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, pass := range m.passes[vehicle.RegistrationNumber] {
+		if pass.covers(vehicle, lotID, t) {
+			return pass, true
+		}
+	}
+	return Pass{}, false
+}
+
+// Coupon takes Percent % off the fee, or Flat off it, e.g. a partner shop discount
+type Coupon struct {
+	Code    string
+	Percent int
+	Flat    int
+	// Expires -> the coupon can not be applied from then on, zero means never
+	Expires time.Time
+}
+
+func (c Coupon) valid(t time.Time) bool {
+	return c.Expires.IsZero() || t.Before(c.Expires)
+}
+
+// CouponChecker is implemented by pricings accepting coupons, ApplyCoupon rejects the codes it does not know
+// and every code when the pricing is no CouponChecker
+type CouponChecker interface {
+	CheckCoupon(code string, at time.Time) error
+}
+
+// DiscountPricing takes the discounts off the fee of Base : the stays of valid pass holders are free,
+// otherwise the coupon applied to the ticket (see ApplyCoupon) is taken off.
+// Put it outermost, wrapped pricings would not see the coupon checks
+type DiscountPricing struct {
+	Base Pricing
+	// Members may be nil, then no pass applies
+	Members *MembershipRegistry
+	Coupons map[string]Coupon
+}
+
+func (ps *DiscountPricing) CheckCoupon(code string, at time.Time) error {
+	coupon, exists := ps.Coupons[code]
+	if !exists {
+		return fmt.Errorf("%w: %s", ErrInvalidCoupon, code)
+	}
+	if !coupon.valid(at) {
+		return fmt.Errorf("%w: %s expired on %v", ErrInvalidCoupon, code, coupon.Expires)
+	}
+	return nil
+}
+
+func (ps *DiscountPricing) CalculatePrice(ticket ParkingTicket) Fee {
+	fee := ps.Base.CalculatePrice(ticket)
+	if fee.Total <= 0 {
+		return fee
+	}
+
+	// passes are checked at the entry time, a stay started under a valid pass stays free
+	if ps.Members != nil {
+		lotID := ""
+		if ref, err := ParseTicketID(ticket.Id); err == nil {
+			lotID = ref.LotId
+		}
+		entry := time.Unix(0, ticket.CheckinTime)
+		if pass, ok := ps.Members.ValidPass(*ticket.VehicleParked, lotID, entry); ok {
+			fee.discount("Pass valid until "+pass.ValidTo.Format(time.DateOnly), fee.Total)
+			return fee
+		}
+	}
+
+	coupon, exists := ps.Coupons[ticket.Coupon]
+	if ticket.Coupon == "" || !exists {
+		return fee
+	}
+	var amount int
+	var description strings.Builder
+	description.WriteString("Coupon " + coupon.Code)
+	switch {
+	case coupon.Percent > 0:
+		amount = fee.Total * min(coupon.Percent, 100) / 100
+		fmt.Fprintf(&description, " -%d%%", coupon.Percent)
+	case coupon.Flat > 0:
+		amount = min(coupon.Flat, fee.Total)
+		fmt.Fprintf(&description, " -%d", coupon.Flat)
+	}
+	if amount > 0 {
+		fee.discount(description.String(), amount)
+	}
+	return fee
+}
+
+func (ps *DiscountPricing) LockRate(vtype VehicleType, occupancy Occupancy) float64 {
+	if locker, ok := ps.Base.(RateLocker); ok {
+		return locker.LockRate(vtype, occupancy)
+	}
+	return 0
+}
+
+func (ps *DiscountPricing) NoShowFee(reservation Reservation) Fee {
+	return noShowFee(ps.Base, reservation)
+}
+
+// ApplyCoupon attaches a coupon code to the active ticket, it is taken off the fee at checkout.
+// The code is checked by the pricing, a pricing that is no CouponChecker takes no coupons
+func (pl *ParkingLot) ApplyCoupon(ticketID string, code string) error {
+	if code == "" {
+		return fmt.Errorf("%w: empty code", ErrInvalidCoupon)
+	}
+	ticket, unlock, err := pl.lockTicket(ticketID)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if err := notActive(ticket); err != nil {
+		return err
+	}
+	now := pl.clock.Now()
+	checker, ok := pl.pricingStrategy.(CouponChecker)
+	if !ok {
+		return fmt.Errorf("%w: %s, the lot takes no coupons", ErrInvalidCoupon, code)
+	}
+	if err := checker.CheckCoupon(code, now); err != nil {
+		return err
+	}
+
+	err = pl.journalEvent(JournalEvent{
+		Kind:     EventCoupon,
+		Time:     now.UnixNano(),
+		TicketId: ticket.Id,
+		FloorId:  ticket.SlotDetails.FloorId,
+		SlotId:   ticket.SlotDetails.Id,
+		Coupon:   code,
+	})
+	if err != nil {
+		return err
+	}
+	ticket.Coupon = code
+	return nil
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func newDiscountLot(t *testing.T, clock *FakeClock, members *MembershipRegistry, coupons map[string]Coupon) *ParkingLot {
+	t.Helper()
+	pricing := &DiscountPricing{Base: &NormalPricing{Clock: clock}, Members: members, Coupons: coupons}
+	lot, err := NewParkingLot(Layout{Floors: []FloorLayout{UniformFloor(Car, 3)}}, pricing, WithID("PR123"), WithClock(clock))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return lot
+}
+
+// parkFor checks vehicle in, lets d elapse and returns the fee charged at the exit
+func parkFor(t *testing.T, lot *ParkingLot, clock *FakeClock, registration string, d time.Duration) Fee {
+	t.Helper()
+	ticket, err := lot.CheckIn(Vehicle{RegistrationNumber: registration, Type: Car})
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(d)
+	receipt, err := lot.Unpark(ticket.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return receipt.Fee
+}
+
+func TestPassHoldersParkFree(t *testing.T) {
+	clock := NewFakeClock(time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC))
+	members := NewMembershipRegistry()
+	march := Pass{
+		ValidFrom: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+		ValidTo:   time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC),
+	}
+	for _, pass := range []Pass{
+		{RegistrationNumber: "KA-01-0001", VehicleType: Car, ValidFrom: march.ValidFrom, ValidTo: march.ValidTo},
+		{RegistrationNumber: "KA-01-0002", VehicleType: Car, ValidFrom: march.ValidFrom, ValidTo: march.ValidTo, Lots: []string{"PR456"}},
+	} {
+		if err := members.Add(pass); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := members.Add(Pass{RegistrationNumber: "KA-01-0003", ValidFrom: march.ValidTo, ValidTo: march.ValidFrom}); !errors.Is(err, ErrInvalidPass) {
+		t.Fatalf("inverted validity err = %v, want ErrInvalidPass", err)
+	}
+	lot := newDiscountLot(t, clock, members, nil)
+
+	if fee := parkFor(t, lot, clock, "KA-01-0001", 2*time.Hour); fee.Total != 0 || len(fee.Discounts()) != 1 {
+		t.Fatalf("pass holder fee %+v, want free", fee)
+	}
+	// the pass is for another lot
+	if fee := parkFor(t, lot, clock, "KA-01-0002", 2*time.Hour); fee.Total != 40 {
+		t.Fatalf("other lot pass fee %d, want 40", fee.Total)
+	}
+	// the pass is checked at the entry : a stay started on the last evening of the pass stays free
+	clock.Set(time.Date(2024, 3, 31, 22, 0, 0, 0, time.UTC))
+	if fee := parkFor(t, lot, clock, "KA-01-0001", 4*time.Hour); fee.Total != 0 {
+		t.Fatalf("stay started under the pass fee %d, want 0", fee.Total)
+	}
+	if fee := parkFor(t, lot, clock, "KA-01-0001", time.Hour); fee.Total != 20 {
+		t.Fatalf("stay after the pass fee %d, want 20", fee.Total)
+	}
+}
+
+func TestCoupons(t *testing.T) {
+	clock := NewFakeClock(time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC))
+	lot := newDiscountLot(t, clock, nil, map[string]Coupon{
+		"SHOP10": {Code: "SHOP10", Percent: 10},
+		"FLAT50": {Code: "FLAT50", Flat: 50},
+		"OLD":    {Code: "OLD", Percent: 50, Expires: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)},
+	})
+
+	for _, tc := range []struct {
+		code string
+		want int
+		err  error
+	}{
+		{code: "SHOP10", want: 40 - 4},
+		{code: "FLAT50", want: 0},
+		{code: "OLD", want: 40, err: ErrInvalidCoupon},
+		{code: "BOGUS", want: 40, err: ErrInvalidCoupon},
+	} {
+		ticket, err := lot.CheckIn(Vehicle{RegistrationNumber: "KA-01-" + tc.code, Type: Car})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := lot.ApplyCoupon(ticket.Id, tc.code); !errors.Is(err, tc.err) {
+			t.Fatalf("coupon %s err = %v, want %v", tc.code, err, tc.err)
+		}
+		clock.Advance(2 * time.Hour)
+		receipt, err := lot.Unpark(ticket.Id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if receipt.Fee.Total != tc.want {
+			t.Fatalf("coupon %s fee %+v, want %d", tc.code, receipt.Fee, tc.want)
+		}
+	}
+}
+
+func TestCouponsNeedACheckingPricing(t *testing.T) {
+	lot, err := NewParkingLot(Layout{Floors: []FloorLayout{UniformFloor(Car, 1)}}, &NormalPricing{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ticket, err := lot.CheckIn(Vehicle{RegistrationNumber: "KA-01-0001", Type: Car})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := lot.ApplyCoupon(ticket.Id, "SHOP10"); !errors.Is(err, ErrInvalidCoupon) {
+		t.Fatalf("coupon on a plain pricing err = %v, want ErrInvalidCoupon", err)
+	}
+}
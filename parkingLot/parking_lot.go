@@ -1,22 +1,76 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"slices"
 	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
+var (
+	ErrNoSlotAvailable = errors.New("no parking slot available")
+	ErrTicketNotFound = errors.New("ticket not found")
+	ErrTicketClosed = errors.New("ticket already closed")
+	ErrTicketPaid = errors.New("ticket already paid")
+	ErrTicketForged = errors.New("ticket does not match the issued ticket")
+)
+
 type ParkingLot struct {
+	// id is the first part of every ticket id
+	id string
 	// multiple parking floors
 	slotStore map[string]*Slot
 	// availableSlots to store available
 	availableSlots map[VehicleType][]*Slot // tbd
+	// total slots per type
+	slotCount map[VehicleType]int
 	// pricing strategy
 	pricingStrategy Pricing
-	// ticketStore
+	// ticketStore holds every ticket issued, closed ones included, by id
 	ticketStore map[string]*ParkingTicket
+	// guards ticketStore map, ticket fields are guarded by the lock of their slot type
+	ticketLock sync.RWMutex
+	// ticketSeq numbers the tickets issued by this lot
+	ticketSeq atomic.Uint64
+	// history holds the closed tickets by checkout time, for the reports
+	history []*ParkingTicket
+	historyLock sync.RWMutex
+	// parkedVehicles maps the registration number of every vehicle inside to its ticket id,
+	// vehicleLock is taken after the pool locks
+	parkedVehicles map[string]string
+	vehicleLock sync.Mutex
+
+	// reservations are guarded by reservationLock, always taken after the pool locks
+	reservations map[string]*Reservation
+	reservationSeq uint64
+	reservationLock sync.Mutex
+	noShowGrace time.Duration
+
+	// waitlist queues the waiters per vehicle type, guarded by waitlistLock, taken after the pool locks
+	waitlist map[VehicleType][]*waiter
+	waiters map[string]*waiter
+	waitSeq uint64
+	waitlistLock sync.Mutex
+	holdTimeout time.Duration
+
+	// drainingFloors are removed once their last vehicle leaves, guarded by every pool lock
+	drainingFloors map[int]bool
+	// events fans out the lot events to the subscribers, see Subscribe
+	events eventBus
+	// payments collects the fees on Checkout, optional
+	payments PaymentProcessor
+
+	clock Clock
+	compatibility Compatibility
+	feeBasis FeeBasis
+	chargerPolicy ChargerPolicy
+	allocation atomic.Pointer[allocator]
+	// journal is optional, journalSeq is the last journal event applied by Restore / Replay
+	journal *Journal
+	journalSeq uint64
 
 	markSlotAvailableLock sync.RWMutex
 	checkInLock sync.Map
@@ -36,16 +90,28 @@ func (pl *ParkingLot) markSlotAvailable(slot *Slot) {
 	pl.markSlotAvailableLock.Lock()
 	defer pl.markSlotAvailableLock.Unlock()
 	vtype := slot.GetVehicleType()
+	slot.IsOccupied = false
+	// a draining slot stays out of the index
+	if slot.Disabled {
+		return
+	}
+	// the first waiter gets the slot before anyone else
+	if pl.offerToWaitlist(slot) {
+		return
+	}
 	slots := pl.availableSlots[vtype]
 	e := len(slots)
 	// change it with comp slots method
-	if compareSlot(slot, slots[e-1]) == 1{
+	// a floor can be single type, so the pool may be empty here
+	if e == 0 || compareSlot(slot, slots[e-1]) > 0 {
 		slots = append(slots, slot)
 		pl.availableSlots[vtype] = slots
+		if e == 0 {
+			pl.publishSlot(LotAvailable, slot)
+		}
 		return
 	} 
 	// lowerBound logic : find the largest element smaller that slot.id
-	slot.IsOccupied = false
 	idx := findInsertIndex(slots, slot)
 
 	slots = append(slots, nil)
@@ -55,6 +121,30 @@ func (pl *ParkingLot) markSlotAvailable(slot *Slot) {
 	pl.availableSlots[vtype] = slots
 	
 }
+// freeSlots returns the availability index of a pool, the pool lock has to be held.
+// The map is shared by every pool, markSlotAvailableLock keeps it from being read while another pool writes it
+func (pl *ParkingLot) freeSlots(vtype VehicleType) []*Slot {
+	pl.markSlotAvailableLock.RLock()
+	defer pl.markSlotAvailableLock.RUnlock()
+	return pl.availableSlots[vtype]
+}
+
+// removeAvailable takes slot out of the availability index, no-op if it is not there
+func (pl *ParkingLot) removeAvailable(slot *Slot) {
+	pl.markSlotAvailableLock.Lock()
+	defer pl.markSlotAvailableLock.Unlock()
+	vtype := slot.GetVehicleType()
+	slots := pl.availableSlots[vtype]
+	idx := findInsertIndex(slots, slot)
+	if idx == len(slots) || slots[idx] != slot {
+		return
+	}
+	pl.availableSlots[vtype] = slices.Delete(slots, idx, idx+1)
+	if len(slots) == 1 {
+		pl.publishSlot(LotFull, slot)
+	}
+}
+
 func findInsertIndex(slots []*Slot, slot *Slot) int {
     l := 0
     r := len(slots)
@@ -71,34 +161,33 @@ func findInsertIndex(slots []*Slot, slot *Slot) int {
 
     return l
 }
-func(pl *ParkingLot) GetAvailableSlots(vtype VehicleType) []*Slot {
-	return pl.availableSlots[vtype]
-} 
 
-// NewParkingLot Init
-// floors -> including ground floor (floor = 3 will mean -> 0,1,2)
-func NewParkingLot(floors int, slots int, ps Pricing) *ParkingLot {
+func slotKey(floor int, id int) string {
+	return strconv.Itoa(floor) + "-" + strconv.Itoa(id)
+}
+
+// indexSlots builds the slot store, the sorted availability index (free slots only) and the per type totals
+func indexSlots(slots []*Slot) (map[string]*Slot, map[VehicleType][]*Slot, map[VehicleType]int) {
 	var slotStore = make(map[string]*Slot)
 	availableSlots := make(map[VehicleType][]*Slot)
-	for i := range(floors) {
-		for j := range(slots) {
-			slot := &Slot{}
-			slotId := strconv.Itoa(i) + "-" + strconv.Itoa(j) 
-			slot.Id = j
-			slot.FloorId = i
-			slotStore[slotId] = slot
-
-			vtype := slot.GetVehicleType()
-
-			if _, exists := availableSlots[vtype]; !exists {
-				availableSlots[vtype] = make([]*Slot, 0)
-			}
-
-			list := availableSlots[vtype]
-			list = append(list, slot)
-			availableSlots[vtype] = list
+	slotCount := make(map[VehicleType]int)
+	for _, slot := range slots {
+		slotStore[slotKey(slot.FloorId, slot.Id)] = slot
+		vtype := slot.GetVehicleType()
+		if _, exists := availableSlots[vtype]; !exists {
+			availableSlots[vtype] = make([]*Slot, 0)
+		}
+		// disabled slots are out of the capacity
+		if !slot.Disabled {
+			slotCount[vtype]++
+		}
+		// occupied, held and disabled slots are not available
+		if !slot.available() {
+			continue
 		}
-		
+		list := availableSlots[vtype]
+		list = append(list, slot)
+		availableSlots[vtype] = list
 	}
 
 	// sort all the lists once
@@ -109,13 +198,112 @@ func NewParkingLot(floors int, slots int, ps Pricing) *ParkingLot {
 		})
 		availableSlots[i] = list
 	}
+	return slotStore, availableSlots, slotCount
+}
 
-	return &ParkingLot{
+// lockAll takes every pool lock in ascending type order, used for whole lot operations
+func (pl *ParkingLot) lockAll() {
+	for _, vtype := range slotTypes {
+		pl.getLock(vtype).Lock()
+	}
+}
+
+func (pl *ParkingLot) unlockAll() {
+	for i := len(slotTypes) - 1; i >= 0; i-- {
+		pl.getLock(slotTypes[i]).Unlock()
+	}
+}
+
+// rlockAll is lockAll for the whole lot reads, e.g. the occupancy report
+func (pl *ParkingLot) rlockAll() {
+	for _, vtype := range slotTypes {
+		pl.getLock(vtype).RLock()
+	}
+}
+
+func (pl *ParkingLot) runlockAll() {
+	for i := len(slotTypes) - 1; i >= 0; i-- {
+		pl.getLock(slotTypes[i]).RUnlock()
+	}
+}
+
+// Option configures a ParkingLot at NewParkingLot time
+type Option func(*ParkingLot)
+
+// DefaultLotID is used when no WithID option is given
+const DefaultLotID = "PL"
+
+// WithID sets the lot id, it must be unique within a LotRegistry
+func WithID(id string) Option {
+	return func(pl *ParkingLot) {
+		pl.id = id
+	}
+}
+
+// WithClock sets the time source used for check in & checkout (default SystemClock)
+func WithClock(clock Clock) Option {
+	return func(pl *ParkingLot) {
+		pl.clock = clock
+	}
+}
+
+// NewParkingLot Init
+// layout -> floors and their slot types, floor & slot ids are positions in the layout (see DefaultLayout)
+func NewParkingLot(layout Layout, ps Pricing, opts ...Option) (*ParkingLot, error) {
+	if err := layout.Validate(); err != nil {
+		return nil, err
+	}
+	slots := make([]*Slot, 0)
+	for i, floor := range layout.Floors {
+		for j, spec := range floor.Slots {
+			slots = append(slots, &Slot{Id: j, FloorId: i, Type: spec.Type, Distance: spec.Distance, Charger: spec.Charger})
+		}
+	}
+	slotStore, availableSlots, slotCount := indexSlots(slots)
+
+	pl := &ParkingLot{
 		slotStore: slotStore,
 		availableSlots: availableSlots,
+		slotCount: slotCount,
 		pricingStrategy: ps,
 		ticketStore: make(map[string]*ParkingTicket),
+		parkedVehicles: make(map[string]string),
+		drainingFloors: make(map[int]bool),
 		markSlotAvailableLock: sync.RWMutex{},
+		clock: SystemClock,
+		id: DefaultLotID,
+		compatibility: DefaultCompatibility(),
+		reservations: make(map[string]*Reservation),
+		noShowGrace: DefaultNoShowGrace,
+		waitlist: make(map[VehicleType][]*waiter),
+		waiters: make(map[string]*waiter),
+		holdTimeout: DefaultWaitlistHoldTimeout,
+	}
+	for _, opt := range opts {
+		opt(pl)
+	}
+	if err := validateLotID(pl.id); err != nil {
+		return nil, err
+	}
+	return pl, nil
+}
+
+func (pl *ParkingLot) ID() string {
+	return pl.id
+}
+
+// ticketID -> <lotId>_<floor>_<slot>_<visit>, see TicketRef
+func (pl *ParkingLot) ticketID(slot *Slot, visit uint64) string {
+	return TicketRef{LotId: pl.id, FloorId: slot.FloorId, SlotId: slot.Id, Visit: visit}.String()
+}
+
+// observeVisit keeps ticketSeq ahead of the tickets loaded by Restore / Replay
+func (pl *ParkingLot) observeVisit(visit uint64) {
+	for {
+		seq := pl.ticketSeq.Load()
+		if visit <= seq || pl.ticketSeq.CompareAndSwap(seq, visit) {
+			return
+		}
 	}
 }
 
@@ -125,61 +313,346 @@ func (pl *ParkingLot) getLock(vtype VehicleType) *sync.RWMutex {
 }
 
 // will return error if unable to park
+// the vehicle gets a slot of its own type, or of the next compatible type when its own pool is full
 func (pl *ParkingLot) CheckIn(vehicle Vehicle) (ParkingTicket, error) {
-	// take lock based on vtype
 	vtype := vehicle.Type
 
-	lock := pl.getLock(vtype)
-
-	lock.Lock()
-	defer lock.Unlock()
-
-	availableSlots := pl.GetAvailableSlots(vtype)
+	// hold the slots of the reservations that just started before picking one
+	pl.ProcessReservations()
+
+	// take lock on every pool the vehicle may use
+	pools := pl.compatibility.slotTypes(vtype)
+	unlock := pl.lockPools(pools)
+	defer unlock()
+
+	// large vehicles need a run of adjacent free slots, candidates are the first slot of every run
+	need := vtype.SlotsRequired()
+	var availableSlots, candidates []*Slot
+	for _, pool := range pools {
+		availableSlots = pl.freeSlots(pool)
+		if candidates = pl.chargerCandidates(vtype, availableSlots, need); len(candidates) > 0 {
+			break
+		}
+	}
 
-	if len(availableSlots) == 0 {
-		return ParkingTicket{}, fmt.Errorf("No Parking Slot available for %v", vtype.ToString()) 
+	if len(candidates) == 0 {
+		return ParkingTicket{}, fmt.Errorf("%w for %v", ErrNoSlotAvailable, vtype.ToString())
 	}
 
-	// get the first slot available
+	// let the allocation strategy pick among the available slots
+	slot := pl.allocationStrategy().Select(vtype, candidates)
+	idx := findInsertIndex(availableSlots, slot)
+	slots := slices.Clone(availableSlots[idx : idx+need])
 
-	slot := availableSlots[0]
+	return pl.issueTicket(vehicle, slots, len(availableSlots), "")
+}
+
+// issueTicket parks vehicle on slots and stores the ticket, free is the free count of the pool
+// before this check in. The pool lock of the slots has to be held
+func (pl *ParkingLot) issueTicket(vehicle Vehicle, slots []*Slot, free int, reservationID string) (ParkingTicket, error) {
+	slot := slots[0]
+
+	// a vehicle already inside can not check in again, it does not use up a visit
+	var visit uint64
+	ticketID, err := pl.indexVehicle(vehicle, func() string {
+		visit = pl.ticketSeq.Add(1)
+		return pl.ticketID(slot, visit)
+	})
+	if err != nil {
+		return ParkingTicket{}, err
+	}
 
 	// create a parking ticket
 
 	parkingTicket := ParkingTicket{
-		Id: vtype.ToString() + "_" + vehicle.RegistrationNumber,
+		Id: ticketID,
+		Visit: visit,
 		VehicleParked: &vehicle,
-		CheckinTime: time.Now().UnixNano(),
+		CheckinTime: pl.clock.Now().UnixNano(),
 		SlotDetails: slot,
+		Slots: slots,
+		BilledAs: pl.billedAs(vehicle, slot),
+	}
+	// surge like pricings fix the rate at the occupancy seen on entry
+	if locker, ok := pl.pricingStrategy.(RateLocker); ok {
+		parkingTicket.RateMultiplier = locker.LockRate(slot.Type, Occupancy{
+			Free: free,
+			Occupied: pl.slotCount[slot.Type] - free,
+		})
 	}
 
-	pl.ticketStore[parkingTicket.Id] = &parkingTicket
-	slot.IsOccupied = true
+	err = pl.journalEvent(JournalEvent{
+		Kind: EventCheckIn,
+		Time: parkingTicket.CheckinTime,
+		TicketId: parkingTicket.Id,
+		Visit: parkingTicket.Visit,
+		Vehicle: &vehicle,
+		FloorId: slot.FloorId,
+		SlotId: slot.Id,
+		SlotCount: len(slots),
+		RateMultiplier: parkingTicket.RateMultiplier,
+		BilledAs: &parkingTicket.BilledAs,
+		ReservationId: reservationID,
+	})
+	if err != nil {
+		pl.unindexVehicle(&parkingTicket)
+		return ParkingTicket{}, err
+	}
 
-	// remove slot from availability list
-	var newList = make([]*Slot, 0)
-	if len(availableSlots) > 1 {
-		newList = availableSlots[1:]
+	pl.ticketLock.Lock()
+	pl.ticketStore[parkingTicket.Id] = &parkingTicket
+	pl.ticketLock.Unlock()
+	pl.publishTicket(VehicleParked, &parkingTicket)
+
+	// remove slots from availability list
+	for _, taken := range slots {
+		taken.IsOccupied = true
+		taken.HeldBy = ""
+		pl.removeAvailable(taken)
 	}
-	
-	pl.availableSlots[vtype] = newList
 
 	return parkingTicket, nil
 }
 
-func (pl *ParkingLot) Checkout(parkingTicket ParkingTicket) int {
+// Unpark closes the ticket, frees the slot and returns the receipt.
+// ticket moves ACTIVE -> CLOSED, or PAID -> CLOSED once paid (see Pay), a closed ticket can not be unparked again.
+// With a payment processor a fee is never left due : an unpaid ticket owing a fee fails with ErrPaymentRequired,
+// the vehicle stays inside until it pays (Pay or Checkout). Without one the fee is left due
+func (pl *ParkingLot) Unpark(ticketID string) (Receipt, error) {
+	return pl.checkout(ticketID, nil)
+}
+
+func (pl *ParkingLot) checkout(ticketID string, payment *Payment) (Receipt, error) {
+	receipt, draining, err := pl.closeTicket(ticketID, payment)
+	// the last vehicle of a draining floor completes its removal
+	if draining {
+		pl.completeDrain()
+	}
+	return receipt, err
+}
 
-	vehicle := parkingTicket.VehicleParked
-	// take lock on vehicleType
-	vtype := vehicle.Type
+// closeTicket does the checkout under the pool lock, draining -> the vehicle left a disabled slot.
+// With a payment the fee is collected first, the vehicle stays inside if it is declined or, with a payment
+// processor, if there is no payment and a fee is due
+func (pl *ParkingLot) closeTicket(ticketID string, payment *Payment) (receipt Receipt, draining bool, err error) {
+	ticket, unlock, err := pl.lockTicket(ticketID)
+	if err != nil {
+		return Receipt{}, false, err
+	}
+	defer unlock()
+
+	if ticket.Status == TicketClosed {
+		return Receipt{}, false, fmt.Errorf("%w: %s", ErrTicketClosed, ticketID)
+	}
+	if payment != nil && ticket.Status == TicketActive {
+		if err := pl.payTicket(ticket, *payment); err != nil {
+			return Receipt{}, false, err
+		}
+	}
+
+	// a paid ticket keeps the fee fixed at payment
+	checkoutTime := pl.clock.Now().UnixNano()
+	fee := ticket.Fee
+	if ticket.Status == TicketActive {
+		fee = pl.feeAt(ticket, checkoutTime)
+		if pl.payments != nil && fee.Total > 0 {
+			return Receipt{}, false, fmt.Errorf("%w: %s owes %d", ErrPaymentRequired, ticketID, fee.Total)
+		}
+	}
+
+	err = pl.journalEvent(JournalEvent{
+		Kind: EventCheckOut,
+		Time: checkoutTime,
+		TicketId: ticket.Id,
+		FloorId: ticket.SlotDetails.FloorId,
+		SlotId: ticket.SlotDetails.Id,
+		Fee: &fee,
+	})
+	if err != nil {
+		return Receipt{}, false, err
+	}
+
+	ticket.CheckoutTime = checkoutTime
+	ticket.Fee = fee
+	pl.publishTicket(VehicleLeft, ticket)
+
+	// mark slots available
+	for _, slot := range ticket.occupiedSlots() {
+		pl.markSlotAvailable(slot)
+	}
+	ticket.Status = TicketClosed
+	pl.unindexVehicle(ticket)
+	pl.recordClosed(ticket)
+
+	return ticket.receipt(), ticket.SlotDetails.Disabled, nil
+}
+
+// Pay settles the fee of a vehicle still inside, as at a pay station : the fee is fixed and the ticket
+// moves ACTIVE -> PAID, the vehicle then leaves with Unpark
+func (pl *ParkingLot) Pay(ticketID string, payment Payment) (Receipt, error) {
+	ticket, unlock, err := pl.lockTicket(ticketID)
+	if err != nil {
+		return Receipt{}, err
+	}
+	defer unlock()
+	if err := pl.payTicket(ticket, payment); err != nil {
+		return Receipt{}, err
+	}
+	return ticket.receipt(), nil
+}
+
+// payTicket fixes the fee of an active ticket and collects it, the ticket stays active if the payment
+// is declined. Expects the pool lock of the ticket
+func (pl *ParkingLot) payTicket(ticket *ParkingTicket, payment Payment) error {
+	if err := notActive(ticket); err != nil {
+		return err
+	}
+	paidTime := pl.clock.Now().UnixNano()
+	fee := pl.feeAt(ticket, paidTime)
+
+	var paid *Transaction
+	if fee.Total > 0 {
+		tx, err := pl.charge(fee.Total, payment, paidTime)
+		if err != nil {
+			return err
+		}
+		paid = &tx
+	}
+
+	err := pl.journalEvent(JournalEvent{
+		Kind: EventPaid,
+		Time: paidTime,
+		TicketId: ticket.Id,
+		FloorId: ticket.SlotDetails.FloorId,
+		SlotId: ticket.SlotDetails.Id,
+		Fee: &fee,
+		Transaction: paid,
+	})
+	if err != nil {
+		// the ticket stays unpaid, the driver gets the money back
+		if paid != nil {
+			if _, refundErr := pl.payments.Refund(*paid, paid.Amount); refundErr != nil {
+				return fmt.Errorf("%w, refund of %s failed: %w", err, paid.Id, refundErr)
+			}
+		}
+		return err
+	}
+
+	ticket.PaidTime = paidTime
+	ticket.Fee = fee
+	if paid != nil {
+		ticket.Payments = append(ticket.Payments, *paid)
+	}
+	ticket.Status = TicketPaid
+	return nil
+}
+
+// feeAt prices the ticket as if the vehicle left at t
+func (pl *ParkingLot) feeAt(ticket *ParkingTicket, t int64) Fee {
+	quote := *ticket
+	quote.CheckoutTime = t
+	return pl.pricingStrategy.CalculatePrice(quote)
+}
 
-	lock := pl.getLock(vtype)
-	lock.Lock()
-	defer lock.Unlock()
+// notActive returns the error of a ticket that can no longer change, nil while the vehicle has not paid
+func notActive(ticket *ParkingTicket) error {
+	switch ticket.Status {
+	case TicketPaid:
+		return fmt.Errorf("%w: %s", ErrTicketPaid, ticket.Id)
+	case TicketClosed:
+		return fmt.Errorf("%w: %s", ErrTicketClosed, ticket.Id)
+	}
+	return nil
+}
 
+func (pl *ParkingLot) lookupTicket(ticketID string) (*ParkingTicket, bool) {
+	pl.ticketLock.RLock()
+	defer pl.ticketLock.RUnlock()
+	ticket, exists := pl.ticketStore[ticketID]
+	return ticket, exists
+}
 
-	price := pl.pricingStrategy.CalculatePrice(parkingTicket)
-	// mark slot available
-	pl.markSlotAvailable(parkingTicket.SlotDetails)
-	return int(price)
-}
\ No newline at end of file
+// lockTicket returns the ticket with the lock of its type held,
+// the lookup is repeated under the lock as Restore may swap the store meanwhile
+func (pl *ParkingLot) lockTicket(ticketID string) (*ParkingTicket, func(), error) {
+	for {
+		ticket, exists := pl.lookupTicket(ticketID)
+		if !exists {
+			return nil, nil, fmt.Errorf("%w: %s", ErrTicketNotFound, ticketID)
+		}
+
+		// take lock on the pool of the slot, it may differ from the vehicle type
+		lock := pl.getLock(ticket.SlotDetails.GetVehicleType())
+		lock.Lock()
+		if current, _ := pl.lookupTicket(ticketID); current == ticket {
+			return ticket, lock.Unlock, nil
+		}
+		lock.Unlock()
+	}
+}
+
+// GetTicket returns a copy of the ticket
+func (pl *ParkingLot) GetTicket(ticketID string) (ParkingTicket, error) {
+	ticket, unlock, err := pl.lockTicket(ticketID)
+	if err != nil {
+		return ParkingTicket{}, err
+	}
+	defer unlock()
+	return *ticket, nil
+}
+
+// Checkout unparks using the ticket handed out by CheckIn, the ticket has to match the issued one.
+// The fee is collected with payment before the slot is freed, a declined payment leaves the vehicle parked
+func (pl *ParkingLot) Checkout(parkingTicket ParkingTicket, payment Payment) (Receipt, error) {
+	issued, exists := pl.lookupTicket(parkingTicket.Id)
+	if !exists {
+		return Receipt{}, fmt.Errorf("%w: %s", ErrTicketNotFound, parkingTicket.Id)
+	}
+	if !issued.matches(parkingTicket) {
+		return Receipt{}, fmt.Errorf("%w: %s", ErrTicketForged, parkingTicket.Id)
+	}
+	return pl.checkout(parkingTicket.Id, &payment)
+}
+
+// matches compares the fields fixed at check in
+func (t *ParkingTicket) matches(other ParkingTicket) bool {
+	if other.VehicleParked == nil || other.SlotDetails == nil {
+		return false
+	}
+	return t.Id == other.Id &&
+		t.CheckinTime == other.CheckinTime &&
+		*t.VehicleParked == *other.VehicleParked &&
+		t.SlotDetails.FloorId == other.SlotDetails.FloorId &&
+		t.SlotDetails.Id == other.SlotDetails.Id
+}
+
+// occupiedSlots -> every slot held by the ticket, at least SlotDetails
+func (t *ParkingTicket) occupiedSlots() []*Slot {
+	if len(t.Slots) == 0 {
+		return []*Slot{t.SlotDetails}
+	}
+	return t.Slots
+}
+
+func (t *ParkingTicket) receipt() Receipt {
+	slotIds := make([]int, 0, len(t.Slots))
+	for _, slot := range t.occupiedSlots() {
+		slotIds = append(slotIds, slot.Id)
+	}
+	return Receipt{
+		TicketId: t.Id,
+		RegistrationNumber: t.VehicleParked.RegistrationNumber,
+		VehicleType: t.VehicleParked.Type,
+		FloorId: t.SlotDetails.FloorId,
+		SlotId: t.SlotDetails.Id,
+		SlotIds: slotIds,
+		CheckinTime: t.CheckinTime,
+		PaidTime: t.PaidTime,
+		CheckoutTime: t.CheckoutTime,
+		EnergyKWh: t.EnergyKWh,
+		Fee: t.Fee,
+		Discounts: t.Fee.Discounts(),
+		Payments: slices.Clone(t.Payments),
+		Balance: t.balance(),
+	}
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func newOneCarLot(t *testing.T) *ParkingLot {
+	t.Helper()
+	lot, err := NewParkingLot(Layout{Floors: []FloorLayout{UniformFloor(Car, 1)}}, &NormalPricing{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return lot
+}
+
+func TestUnparkClosesTheTicket(t *testing.T) {
+	lot := newOneCarLot(t)
+	ticket, err := lot.CheckIn(Vehicle{RegistrationNumber: "KA-01-0001", Type: Car})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := lot.CheckIn(Vehicle{RegistrationNumber: "KA-01-0002", Type: Car}); err == nil {
+		t.Fatalf("second car parked on a full lot")
+	}
+
+	receipt, err := lot.Unpark(ticket.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if receipt.TicketId != ticket.Id || receipt.RegistrationNumber != "KA-01-0001" || receipt.CheckoutTime < receipt.CheckinTime {
+		t.Fatalf("receipt %+v", receipt)
+	}
+	if _, err := lot.Unpark(ticket.Id); !errors.Is(err, ErrTicketClosed) {
+		t.Fatalf("second Unpark err = %v, want ErrTicketClosed", err)
+	}
+	// the slot is free again
+	if _, err := lot.CheckIn(Vehicle{RegistrationNumber: "KA-01-0002", Type: Car}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUnparkAfterSlotReuse(t *testing.T) {
+	lot := newOneCarLot(t)
+	first, err := lot.CheckIn(Vehicle{RegistrationNumber: "KA-01-0001", Type: Car})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := lot.Unpark(first.Id); err != nil {
+		t.Fatal(err)
+	}
+	second, err := lot.CheckIn(Vehicle{RegistrationNumber: "KA-01-0002", Type: Car})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.SlotDetails.Id != first.SlotDetails.Id || second.Id == first.Id {
+		t.Fatalf("second visit %s on slot %d, first visit %s", second.Id, second.SlotDetails.Id, first.Id)
+	}
+
+	// the old ticket can not let the new vehicle out
+	if _, err := lot.Unpark(first.Id); !errors.Is(err, ErrTicketClosed) {
+		t.Fatalf("Unpark of the first visit err = %v, want ErrTicketClosed", err)
+	}
+	if _, err := lot.Checkout(first, Payment{Method: PayCash}); !errors.Is(err, ErrTicketClosed) {
+		t.Fatalf("Checkout of the first visit err = %v, want ErrTicketClosed", err)
+	}
+	reused := first
+	reused.Id = second.Id
+	if _, err := lot.Checkout(reused, Payment{Method: PayCash}); !errors.Is(err, ErrTicketForged) {
+		t.Fatalf("Checkout of the first visit under the new id err = %v, want ErrTicketForged", err)
+	}
+	if got, _ := lot.GetTicket(second.Id); got.Status != TicketActive || lot.FreeSlotCount(Car) != 0 {
+		t.Fatalf("second visit %s with %d free slots", got.Status.ToString(), lot.FreeSlotCount(Car))
+	}
+}
+
+func TestUnparkUnknownTicket(t *testing.T) {
+	lot := newOneCarLot(t)
+	for _, id := range []string{"Car_KA-01-0001", "PL_0_0_1", ""} {
+		if _, err := lot.Unpark(id); !errors.Is(err, ErrTicketNotFound) {
+			t.Fatalf("Unpark(%q) err = %v, want ErrTicketNotFound", id, err)
+		}
+	}
+	if _, err := lot.Checkout(ParkingTicket{Id: "Car_KA-01-0001"}, Payment{Method: PayCash}); !errors.Is(err, ErrTicketNotFound) {
+		t.Fatalf("Checkout err = %v, want ErrTicketNotFound", err)
+	}
+}
+
+func TestCheckoutRejectsForgedTickets(t *testing.T) {
+	lot, err := NewParkingLot(Layout{Floors: []FloorLayout{UniformFloor(Car, 1)}}, &NormalPricing{}, WithPaymentProcessor(NewFakeGateway()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ticket, err := lot.CheckIn(Vehicle{RegistrationNumber: "KA-01-0001", Type: Car})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cash := Payment{Method: PayCash, Tendered: 100}
+	otherSlot := *ticket.SlotDetails
+	otherSlot.Id++
+	forged := map[string]func(*ParkingTicket){
+		"later check in": func(p *ParkingTicket) { p.CheckinTime++ },
+		"other vehicle":  func(p *ParkingTicket) { p.VehicleParked = &Vehicle{RegistrationNumber: "KA-01-0009", Type: Car} },
+		"other slot":     func(p *ParkingTicket) { p.SlotDetails = &otherSlot },
+		"no vehicle":     func(p *ParkingTicket) { p.VehicleParked = nil },
+	}
+	for name, forge := range forged {
+		copied := ticket
+		forge(&copied)
+		if _, err := lot.Checkout(copied, cash); !errors.Is(err, ErrTicketForged) {
+			t.Errorf("%s: Checkout err = %v, want ErrTicketForged", name, err)
+		}
+	}
+
+	if _, err := lot.Checkout(ticket, cash); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := lot.Checkout(ticket, cash); !errors.Is(err, ErrTicketClosed) {
+		t.Fatalf("second Checkout err = %v, want ErrTicketClosed", err)
+	}
+	if _, err := lot.Unpark(ticket.Id); !errors.Is(err, ErrTicketClosed) {
+		t.Fatalf("Unpark after Checkout err = %v, want ErrTicketClosed", err)
+	}
+}
+
+func TestPayThenUnpark(t *testing.T) {
+	clock := NewFakeClock(time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC))
+	gateway := NewFakeGateway()
+	lot, err := NewParkingLot(Layout{Floors: []FloorLayout{UniformFloor(Car, 2)}}, &NormalPricing{Clock: clock},
+		WithClock(clock), WithPaymentProcessor(gateway))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ticket, err := lot.CheckIn(Vehicle{RegistrationNumber: "KA-01-0001", Type: Car})
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(2 * time.Hour)
+
+	paid, err := lot.Pay(ticket.Id, Payment{Method: PayCash, Tendered: 1000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if paid.Balance != 0 || paid.PaidTime == 0 || paid.CheckoutTime != 0 {
+		t.Fatalf("pay receipt %+v", paid)
+	}
+	got, _ := lot.GetTicket(ticket.Id)
+	if got.Status != TicketPaid {
+		t.Fatalf("status after Pay = %s, want PAID", got.Status.ToString())
+	}
+	if _, err := lot.Pay(ticket.Id, Payment{Method: PayCash, Tendered: 1000}); !errors.Is(err, ErrTicketPaid) {
+		t.Fatalf("second Pay err = %v, want ErrTicketPaid", err)
+	}
+
+	// the fee was fixed at the pay station
+	clock.Advance(time.Hour)
+	receipt, err := lot.Unpark(ticket.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if receipt.Fee.Total != paid.Fee.Total || receipt.Balance != 0 {
+		t.Fatalf("unpark receipt %+v, paid %+v", receipt, paid)
+	}
+	got, _ = lot.GetTicket(ticket.Id)
+	if got.Status != TicketClosed {
+		t.Fatalf("status after Unpark = %s, want CLOSED", got.Status.ToString())
+	}
+}
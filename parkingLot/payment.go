@@ -0,0 +1,312 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+)
+
+var (
+	ErrPaymentDeclined    = errors.New("payment declined")
+	ErrPaymentRequired    = errors.New("payment required")
+	ErrNoPaymentProcessor = errors.New("no payment processor")
+	ErrInvalidRefund      = errors.New("invalid refund")
+	ErrInvalidAdjustment  = errors.New("invalid adjustment")
+	ErrTicketNotPaid      = errors.New("ticket not paid")
+)
+
+type PaymentMethod string
+
+const (
+	PayCash   PaymentMethod = "cash"
+	PayCard   PaymentMethod = "card"
+	PayWallet PaymentMethod = "wallet"
+)
+
+// Payment is how the driver pays at the exit
+type Payment struct {
+	Method PaymentMethod `json:"method"`
+	// Account -> card number or wallet id, unused for cash
+	Account string `json:"account,omitempty"`
+	// Tendered -> cash handed over, the change is given back
+	Tendered int `json:"tendered,omitempty"`
+}
+
+type TransactionKind string
+
+const (
+	TxPayment TransactionKind = "payment"
+	TxRefund  TransactionKind = "refund"
+	// TxAdjustment corrects the amount due after checkout without moving money,
+	// positive -> the driver owes more, negative -> the lot owes the driver
+	TxAdjustment TransactionKind = "adjustment"
+)
+
+// Transaction is one entry of the ledger of a ticket, Amount is always positive except for adjustments
+type Transaction struct {
+	Id     string          `json:"id"`
+	Kind   TransactionKind `json:"kind"`
+	Method PaymentMethod   `json:"method,omitempty"`
+	Amount int             `json:"amount"`
+	// Change -> cash given back on a payment
+	Change int    `json:"change,omitempty"`
+	Reason string `json:"reason,omitempty"`
+	Time   int64  `json:"time"`
+}
+
+// PaymentProcessor moves the money, the lot fills Kind, Reason & Time of the transactions it returns
+type PaymentProcessor interface {
+	// Charge takes amount with payment, ErrPaymentDeclined when refused
+	Charge(amount int, payment Payment) (Transaction, error)
+	// Refund pays amount back on the charge, in the method it was paid with
+	Refund(charge Transaction, amount int) (Transaction, error)
+}
+
+// WithPaymentProcessor sets the processor used by Checkout & Refund
+func WithPaymentProcessor(processor PaymentProcessor) Option {
+	return func(pl *ParkingLot) {
+		pl.payments = processor
+	}
+}
+
+// FakeGateway is an in-memory PaymentProcessor for tests & demos : cash needs enough tendered,
+// cards are accepted unless declined, wallets need the balance
+type FakeGateway struct {
+	mu       sync.Mutex
+	seq      uint64
+	declined map[string]bool
+	wallets  map[string]int
+	charges  map[string]*fakeCharge
+}
+
+type fakeCharge struct {
+	payment  Payment
+	amount   int
+	refunded int
+}
+
+func NewFakeGateway() *FakeGateway {
+	return &FakeGateway{declined: make(map[string]bool), wallets: make(map[string]int), charges: make(map[string]*fakeCharge)}
+}
+
+// DeclineCard makes every charge on the card fail
+func (g *FakeGateway) DeclineCard(number string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.declined[number] = true
+}
+
+// TopUp credits the wallet, creating it if needed
+func (g *FakeGateway) TopUp(wallet string, amount int) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.wallets[wallet] += amount
+}
+
+// Balance returns what is left on the wallet
+func (g *FakeGateway) Balance(wallet string) int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.wallets[wallet]
+}
+
+func (g *FakeGateway) nextID(prefix string) string {
+	g.seq++
+	return prefix + strconv.FormatUint(g.seq, 10)
+}
+
+func (g *FakeGateway) Charge(amount int, payment Payment) (Transaction, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	tx := Transaction{Method: payment.Method, Amount: amount}
+	switch payment.Method {
+	case PayCash:
+		if payment.Tendered < amount {
+			return Transaction{}, fmt.Errorf("%w: %d tendered, %d due", ErrPaymentDeclined, payment.Tendered, amount)
+		}
+		tx.Change = payment.Tendered - amount
+	case PayCard:
+		if payment.Account == "" || g.declined[payment.Account] {
+			return Transaction{}, fmt.Errorf("%w: card %q refused", ErrPaymentDeclined, payment.Account)
+		}
+	case PayWallet:
+		balance, exists := g.wallets[payment.Account]
+		if !exists || balance < amount {
+			return Transaction{}, fmt.Errorf("%w: wallet %q has %d, %d due", ErrPaymentDeclined, payment.Account, balance, amount)
+		}
+		g.wallets[payment.Account] = balance - amount
+	default:
+		return Transaction{}, fmt.Errorf("%w: unknown method %q", ErrPaymentDeclined, payment.Method)
+	}
+	tx.Id = g.nextID("P")
+	g.charges[tx.Id] = &fakeCharge{payment: payment, amount: amount}
+	return tx, nil
+}
+
+func (g *FakeGateway) Refund(charge Transaction, amount int) (Transaction, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	paid, exists := g.charges[charge.Id]
+	if !exists {
+		return Transaction{}, fmt.Errorf("%w: unknown charge %s", ErrInvalidRefund, charge.Id)
+	}
+	if amount <= 0 || paid.refunded+amount > paid.amount {
+		return Transaction{}, fmt.Errorf("%w: %d on %s", ErrInvalidRefund, amount, charge.Id)
+	}
+	paid.refunded += amount
+	if paid.payment.Method == PayWallet {
+		g.wallets[paid.payment.Account] += amount
+	}
+	return Transaction{Id: g.nextID("R"), Method: charge.Method, Amount: amount}, nil
+}
+
+// charge collects amount for a ticket paid at paidTime
+func (pl *ParkingLot) charge(amount int, payment Payment, paidTime int64) (Transaction, error) {
+	if pl.payments == nil {
+		return Transaction{}, ErrNoPaymentProcessor
+	}
+	tx, err := pl.payments.Charge(amount, payment)
+	if err != nil {
+		return Transaction{}, err
+	}
+	tx.Kind = TxPayment
+	tx.Time = paidTime
+	return tx, nil
+}
+
+// balance -> what the driver still owes on a paid or closed ticket, negative when the lot owes the driver
+func (t *ParkingTicket) balance() int {
+	if t.Status == TicketActive {
+		return 0
+	}
+	due := t.Fee.Total
+	for _, tx := range t.Payments {
+		switch tx.Kind {
+		case TxPayment:
+			due -= tx.Amount
+		case TxRefund, TxAdjustment:
+			due += tx.Amount
+		}
+	}
+	return due
+}
+
+// refundable -> what was paid and not refunded yet, and the payment to refund it on
+func (t *ParkingTicket) refundable() (int, *Transaction) {
+	var paid *Transaction
+	amount := 0
+	for i, tx := range t.Payments {
+		switch tx.Kind {
+		case TxPayment:
+			amount += tx.Amount
+			paid = &t.Payments[i]
+		case TxRefund:
+			amount -= tx.Amount
+		}
+	}
+	return amount, paid
+}
+
+// Receipt returns the itemised receipt of a ticket with its ledger, it follows the refunds & adjustments
+func (pl *ParkingLot) Receipt(ticketID string) (Receipt, error) {
+	ticket, err := pl.GetTicket(ticketID)
+	if err != nil {
+		return Receipt{}, err
+	}
+	return ticket.receipt(), nil
+}
+
+// Refund pays back the credit of a checked out ticket on its payment, the credit comes from a
+// negative adjustment (see Adjust). At most what was paid is refunded
+func (pl *ParkingLot) Refund(ticketID string, amount int, reason string) (Transaction, error) {
+	ticket, unlock, err := pl.lockTicket(ticketID)
+	if err != nil {
+		return Transaction{}, err
+	}
+	defer unlock()
+
+	if ticket.Status == TicketActive {
+		return Transaction{}, fmt.Errorf("%w: ticket %s has not paid", ErrInvalidRefund, ticketID)
+	}
+	refundable, paid := ticket.refundable()
+	if paid == nil {
+		return Transaction{}, fmt.Errorf("%w: nothing paid on ticket %s", ErrInvalidRefund, ticketID)
+	}
+	refundable = min(refundable, -ticket.balance())
+	if amount <= 0 || amount > refundable {
+		return Transaction{}, fmt.Errorf("%w: %d requested, %d refundable on ticket %s", ErrInvalidRefund, amount, max(refundable, 0), ticketID)
+	}
+	if pl.payments == nil {
+		return Transaction{}, ErrNoPaymentProcessor
+	}
+	tx, err := pl.payments.Refund(*paid, amount)
+	if err != nil {
+		return Transaction{}, err
+	}
+	tx.Kind = TxRefund
+	tx.Reason = reason
+	tx.Time = pl.clock.Now().UnixNano()
+	if err := pl.recordTransaction(ticket, tx); err != nil {
+		return Transaction{}, fmt.Errorf("refund %s done but not recorded: %w", tx.Id, err)
+	}
+	return tx, nil
+}
+
+// Adjust corrects the amount due on a checked out ticket, e.g. a damage charge or a goodwill credit.
+// No money moves, a credit (negative amount) can then be refunded
+func (pl *ParkingLot) Adjust(ticketID string, amount int, reason string) (Transaction, error) {
+	if amount == 0 || reason == "" {
+		return Transaction{}, fmt.Errorf("%w: it needs an amount and a reason", ErrInvalidAdjustment)
+	}
+	ticket, unlock, err := pl.lockTicket(ticketID)
+	if err != nil {
+		return Transaction{}, err
+	}
+	defer unlock()
+
+	if ticket.Status == TicketActive {
+		return Transaction{}, fmt.Errorf("%w: %s", ErrTicketNotPaid, ticketID)
+	}
+	adjustments := 1
+	for _, tx := range ticket.Payments {
+		if tx.Kind == TxAdjustment {
+			adjustments++
+		}
+	}
+	tx := Transaction{
+		Id:     ticket.Id + "_A" + strconv.Itoa(adjustments),
+		Kind:   TxAdjustment,
+		Amount: amount,
+		Reason: reason,
+		Time:   pl.clock.Now().UnixNano(),
+	}
+	if err := pl.recordTransaction(ticket, tx); err != nil {
+		return Transaction{}, err
+	}
+	return tx, nil
+}
+
+// recordTransaction journals tx and adds it to the ledger of ticket, the pool lock of the ticket has to be held
+func (pl *ParkingLot) recordTransaction(ticket *ParkingTicket, tx Transaction) error {
+	err := pl.journalEvent(JournalEvent{
+		Kind:        EventPayment,
+		Time:        tx.Time,
+		TicketId:    ticket.Id,
+		FloorId:     ticket.SlotDetails.FloorId,
+		SlotId:      ticket.SlotDetails.Id,
+		Transaction: &tx,
+	})
+	if err != nil {
+		return err
+	}
+	pl.appendLedger(ticket, tx)
+	return nil
+}
+
+// appendLedger adds tx to a closed ticket, History copies the closed tickets under the history lock
+func (pl *ParkingLot) appendLedger(ticket *ParkingTicket, tx Transaction) {
+	pl.historyLock.Lock()
+	defer pl.historyLock.Unlock()
+	ticket.Payments = append(ticket.Payments, tx)
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// newPayingLot is a 2 Car slots lot taking payments on the fake gateway
+func newPayingLot(t *testing.T) (*ParkingLot, *FakeClock, *FakeGateway) {
+	t.Helper()
+	clock := NewFakeClock(time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC))
+	gateway := NewFakeGateway()
+	lot, err := NewParkingLot(Layout{Floors: []FloorLayout{UniformFloor(Car, 2)}}, &NormalPricing{Clock: clock},
+		WithClock(clock), WithPaymentProcessor(gateway))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return lot, clock, gateway
+}
+
+func TestCheckoutPayments(t *testing.T) {
+	lot, clock, gateway := newPayingLot(t)
+	gateway.TopUp("W-1", 30)
+	gateway.DeclineCard("4000")
+	ticket, err := lot.CheckIn(Vehicle{RegistrationNumber: "KA-01-0001", Type: Car})
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(2 * time.Hour)
+
+	for _, declined := range []Payment{
+		{Method: PayCard, Account: "4000"},
+		{Method: PayWallet, Account: "W-1"},
+		{Method: PayCash, Tendered: 39},
+		{Method: "cheque"},
+	} {
+		if _, err := lot.Checkout(ticket, declined); !errors.Is(err, ErrPaymentDeclined) {
+			t.Fatalf("%+v: Checkout err = %v, want ErrPaymentDeclined", declined, err)
+		}
+	}
+	// the vehicle is still inside
+	if got, _ := lot.GetTicket(ticket.Id); got.Status != TicketActive {
+		t.Fatalf("ticket %s after declined payments", got.Status.ToString())
+	}
+	if gateway.Balance("W-1") != 30 {
+		t.Fatalf("wallet balance %d after a declined payment, want 30", gateway.Balance("W-1"))
+	}
+
+	receipt, err := lot.Checkout(ticket, Payment{Method: PayCash, Tendered: 50})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if receipt.Fee.Total != 40 || receipt.Balance != 0 || len(receipt.Payments) != 1 {
+		t.Fatalf("receipt %+v", receipt)
+	}
+	if paid := receipt.Payments[0]; paid.Kind != TxPayment || paid.Amount != 40 || paid.Change != 10 || paid.Method != PayCash {
+		t.Fatalf("payment %+v", paid)
+	}
+}
+
+func TestAdjustAndRefund(t *testing.T) {
+	lot, clock, gateway := newPayingLot(t)
+	gateway.TopUp("W-1", 100)
+	ticket, err := lot.CheckIn(Vehicle{RegistrationNumber: "KA-01-0001", Type: Car})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := lot.Refund(ticket.Id, 10, "early"); !errors.Is(err, ErrInvalidRefund) {
+		t.Fatalf("Refund of an active ticket err = %v, want ErrInvalidRefund", err)
+	}
+	if _, err := lot.Adjust(ticket.Id, -10, "early"); !errors.Is(err, ErrTicketNotPaid) {
+		t.Fatalf("Adjust of an active ticket err = %v, want ErrTicketNotPaid", err)
+	}
+	clock.Advance(2 * time.Hour)
+	if _, err := lot.Checkout(ticket, Payment{Method: PayWallet, Account: "W-1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// nothing is owed to the driver until a credit is given
+	if _, err := lot.Refund(ticket.Id, 10, "goodwill"); !errors.Is(err, ErrInvalidRefund) {
+		t.Fatalf("Refund without credit err = %v, want ErrInvalidRefund", err)
+	}
+	for _, bad := range []struct {
+		amount int
+		reason string
+	}{{0, "nothing"}, {-15, ""}} {
+		if _, err := lot.Adjust(ticket.Id, bad.amount, bad.reason); !errors.Is(err, ErrInvalidAdjustment) {
+			t.Fatalf("Adjust(%d, %q) err = %v, want ErrInvalidAdjustment", bad.amount, bad.reason, err)
+		}
+	}
+	if _, err := lot.Adjust(ticket.Id, -15, "barrier stuck"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := lot.Refund(ticket.Id, 20, "barrier stuck"); !errors.Is(err, ErrInvalidRefund) {
+		t.Fatalf("Refund above the credit err = %v, want ErrInvalidRefund", err)
+	}
+	refund, err := lot.Refund(ticket.Id, 15, "barrier stuck")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if refund.Kind != TxRefund || refund.Method != PayWallet || refund.Amount != 15 {
+		t.Fatalf("refund %+v", refund)
+	}
+	if gateway.Balance("W-1") != 100-40+15 {
+		t.Fatalf("wallet balance %d, want %d", gateway.Balance("W-1"), 100-40+15)
+	}
+
+	receipt, err := lot.Receipt(ticket.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if receipt.Balance != 0 || len(receipt.Payments) != 3 {
+		t.Fatalf("receipt %+v", receipt)
+	}
+	for i, kind := range []TransactionKind{TxPayment, TxAdjustment, TxRefund} {
+		if receipt.Payments[i].Kind != kind {
+			t.Fatalf("ledger %+v, want payment, adjustment, refund", receipt.Payments)
+		}
+	}
+}
+
+// the ledger of a visit stays reachable by its id once the slot is reused
+func TestRefundEarlierVisit(t *testing.T) {
+	lot, clock, gateway := newPayingLot(t)
+	gateway.TopUp("W-1", 100)
+	first, err := lot.CheckIn(Vehicle{RegistrationNumber: "KA-01-0001", Type: Car})
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(2 * time.Hour)
+	if _, err := lot.Checkout(first, Payment{Method: PayWallet, Account: "W-1"}); err != nil {
+		t.Fatal(err)
+	}
+	second, err := lot.CheckIn(Vehicle{RegistrationNumber: "KA-01-0002", Type: Car})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.SlotDetails.Id != first.SlotDetails.Id {
+		t.Fatalf("second visit on slot %d, want slot %d", second.SlotDetails.Id, first.SlotDetails.Id)
+	}
+
+	if _, err := lot.Adjust(first.Id, -10, "barrier stuck"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := lot.Refund(first.Id, 10, "barrier stuck"); err != nil {
+		t.Fatal(err)
+	}
+	if gateway.Balance("W-1") != 100-40+10 {
+		t.Fatalf("wallet balance %d, want %d", gateway.Balance("W-1"), 100-40+10)
+	}
+	receipt, err := lot.Receipt(first.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if receipt.RegistrationNumber != "KA-01-0001" || receipt.Balance != 0 || len(receipt.Payments) != 3 {
+		t.Fatalf("first visit receipt %+v", receipt)
+	}
+	// the vehicle parked now has its own, empty ledger
+	if _, err := lot.Adjust(second.Id, -10, "barrier stuck"); !errors.Is(err, ErrTicketNotPaid) {
+		t.Fatalf("Adjust of the second visit err = %v, want ErrTicketNotPaid", err)
+	}
+	if receipt, _ := lot.Receipt(second.Id); receipt.RegistrationNumber != "KA-01-0002" || len(receipt.Payments) != 0 {
+		t.Fatalf("second visit receipt %+v", receipt)
+	}
+}
+
+func TestRefundNeedsProcessor(t *testing.T) {
+	clock := NewFakeClock(time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC))
+	lot, err := NewParkingLot(Layout{Floors: []FloorLayout{UniformFloor(Car, 1)}}, &NormalPricing{Clock: clock}, WithClock(clock))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ticket, _ := lot.CheckIn(Vehicle{RegistrationNumber: "KA-01-0001", Type: Car})
+	clock.Advance(time.Hour)
+	if _, err := lot.Checkout(ticket, Payment{Method: PayCash, Tendered: 20}); !errors.Is(err, ErrNoPaymentProcessor) {
+		t.Fatalf("Checkout without processor err = %v, want ErrNoPaymentProcessor", err)
+	}
+	// Unpark still lets the vehicle out, unpaid
+	receipt, err := lot.Unpark(ticket.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if receipt.Balance != 20 {
+		t.Fatalf("balance %d after an unpaid exit, want 20", receipt.Balance)
+	}
+}
+
+func TestUnparkRequiresPayment(t *testing.T) {
+	clock := NewFakeClock(time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC))
+	lot, err := NewParkingLot(Layout{Floors: []FloorLayout{UniformFloor(Car, 1)}}, &NormalPricing{Clock: clock},
+		WithClock(clock), WithPaymentProcessor(NewFakeGateway()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ticket, err := lot.CheckIn(Vehicle{RegistrationNumber: "KA-01-0001", Type: Car})
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(time.Hour)
+
+	if _, err := lot.Unpark(ticket.Id); !errors.Is(err, ErrPaymentRequired) {
+		t.Fatalf("Unpark err = %v, want ErrPaymentRequired", err)
+	}
+	got, _ := lot.GetTicket(ticket.Id)
+	if got.Status != TicketActive || lot.FreeSlotCount(Car) != 0 {
+		t.Fatalf("status %s, %d free slots after a refused unpark", got.Status.ToString(), lot.FreeSlotCount(Car))
+	}
+	receipt, err := lot.Checkout(ticket, Payment{Method: PayCash, Tendered: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if receipt.Balance != 0 || lot.FreeSlotCount(Car) != 1 {
+		t.Fatalf("checkout receipt %+v, %d free slots", receipt, lot.FreeSlotCount(Car))
+	}
+}
+
+// refuseRefunds takes payments but can not pay anything back
+type refuseRefunds struct {
+	*FakeGateway
+}
+
+var errRefundsDown = errors.New("refunds are down")
+
+func (g refuseRefunds) Refund(Transaction, int) (Transaction, error) {
+	return Transaction{}, errRefundsDown
+}
+
+// a payment that can not be journaled is refunded, a failing refund is reported along with the journal error
+func TestPayReportsFailedRefund(t *testing.T) {
+	clock := NewFakeClock(time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC))
+	journal, err := OpenJournal(filepath.Join(t.TempDir(), "journal.log"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lot, err := NewParkingLot(Layout{Floors: []FloorLayout{UniformFloor(Car, 1)}}, &NormalPricing{Clock: clock},
+		WithClock(clock), WithJournal(journal), WithPaymentProcessor(refuseRefunds{NewFakeGateway()}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ticket, err := lot.CheckIn(Vehicle{RegistrationNumber: "KA-01-0001", Type: Car})
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(time.Hour)
+	// every journal write fails from now on
+	if err := journal.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = lot.Pay(ticket.Id, Payment{Method: PayCard, Account: "4111"})
+	if !errors.Is(err, errRefundsDown) || !errors.Is(err, os.ErrClosed) {
+		t.Fatalf("Pay err = %v, want the journal and the refund errors", err)
+	}
+	got, _ := lot.GetTicket(ticket.Id)
+	if got.Status != TicketActive || len(got.Payments) != 0 {
+		t.Fatalf("ticket %s with %d payments after a failed pay", got.Status.ToString(), len(got.Payments))
+	}
+}
@@ -1,21 +1,163 @@
 package main
 
 import (
+	"fmt"
 	"time"
 )
 
 type Pricing interface {
-	CalculatePrice(ticket ParkingTicket) int
+	CalculatePrice(ticket ParkingTicket) Fee
 }
 
+// FeeLine is one item of a fee breakdown, discounts & caps are negative amounts
+type FeeLine struct {
+	Description string `json:"description"`
+	Amount      int    `json:"amount"`
+	// Discount -> the line comes from a pass or a coupon, see DiscountPricing
+	Discount bool `json:"discount,omitempty"`
+}
+
+// Fee is the breakdown returned by a Pricing, Total is the sum of all the lines
+type Fee struct {
+	Lines []FeeLine `json:"lines"`
+	Total int       `json:"total"`
+}
+
+func (f *Fee) add(description string, amount int) {
+	f.Lines = append(f.Lines, FeeLine{Description: description, Amount: amount})
+	f.Total += amount
+}
+
+// discount takes amount off the fee
+func (f *Fee) discount(description string, amount int) {
+	f.Lines = append(f.Lines, FeeLine{Description: description, Amount: -amount, Discount: true})
+	f.Total -= amount
+}
+
+// Discounts returns the discount lines of the fee
+func (f Fee) Discounts() []FeeLine {
+	discounts := make([]FeeLine, 0)
+	for _, line := range f.Lines {
+		if line.Discount {
+			discounts = append(discounts, line)
+		}
+	}
+	return discounts
+}
+
+// checkoutTime of an active ticket is clock's now, so it can be quoted before unparking
+func checkoutTime(ticket ParkingTicket, clock Clock) time.Time {
+	if ticket.CheckoutTime == 0 {
+		return orSystemClock(clock).Now()
+	}
+	return time.Unix(0, ticket.CheckoutTime)
+}
+
+// stayDuration is checkout - checkin
+func stayDuration(ticket ParkingTicket, clock Clock) time.Duration {
+	return time.Duration(checkoutTime(ticket, clock).UnixNano() - ticket.CheckinTime)
+}
+
+// NormalPricing bills BasePrice per started hour
+type NormalPricing struct {
+	// Clock prices active tickets, nil means SystemClock
+	Clock Clock
+}
+
+func (ps *NormalPricing) CalculatePrice(ticket ParkingTicket) Fee {
+	hourly := MeteredPricing{Policy: BillingPolicy{Unit: time.Hour, Rounding: RoundUp}, Clock: ps.Clock}
+	return hourly.CalculatePrice(ticket)
+}
+
+type Rounding int
+
+const (
+	// RoundUp -> every started unit is billed
+	RoundUp Rounding = iota
+	// RoundDown -> only complete units are billed
+	RoundDown
+	// RoundNearest -> half a unit or more is billed as a full one
+	RoundNearest
+)
+
+// BillingPolicy holds the rules applied on top of the hourly rate
+type BillingPolicy struct {
+	// Unit is the billing block, e.g. time.Hour or 15 * time.Minute (default time.Hour)
+	Unit     time.Duration
+	Rounding Rounding
+	// GracePeriod -> first N minutes of every stay are free
+	GracePeriod time.Duration
+	// MinimumCharge applies to every stay longer than the grace period
+	MinimumCharge int
+	// DailyCap is the max charged per 24h of stay, 0 means no cap
+	DailyCap int
+}
+
+// MeteredPricing bills an hourly rate per vehicle type following a BillingPolicy,
+// stays longer than a day are billed day by day so the daily cap applies to each of them
+type MeteredPricing struct {
+	Policy BillingPolicy
+	// Rates -> price per hour, types missing here fall back to VehicleType.BasePrice
+	Rates map[VehicleType]int
+	// Clock prices active tickets, nil means SystemClock
+	Clock Clock
+}
 
-type NormalPricing struct {}
+const day = 24 * time.Hour
 
-func (ps *NormalPricing) CalculatePrice(ticket ParkingTicket) int {
-	totalTimeInNano := time.Now().UnixNano() - ticket.CheckinTime
+func (ps *MeteredPricing) rate(vtype VehicleType) int {
+	if rate, exists := ps.Rates[vtype]; exists {
+		return rate
+	}
+	return vtype.BasePrice()
+}
+
+func (ps *MeteredPricing) CalculatePrice(ticket ParkingTicket) Fee {
+	policy := ps.Policy
+	if policy.Unit <= 0 {
+		policy.Unit = time.Hour
+	}
+	rate := ps.rate(ticket.BilledAs)
+	stay := stayDuration(ticket, ps.Clock)
+
+	var fee Fee
+	if stay <= policy.GracePeriod {
+		fee.add("Grace period", 0)
+		return fee
+	}
+	billable := stay - policy.GracePeriod
+
+	for d := 1; billable > 0; d++ {
+		chunk := min(billable, day)
+		billable -= chunk
 
-	timeDur := time.Duration(totalTimeInNano)
-	seconds := timeDur.Seconds()
+		units := roundUnits(chunk, policy.Unit, policy.Rounding)
+		amount := int(int64(rate) * units * int64(policy.Unit) / int64(time.Hour))
+		fee.add(fmt.Sprintf("Day %d: %d x %v @ %d/h", d, units, policy.Unit, rate), amount)
 
-	return int(seconds) * (ticket.VehicleParked.Type).BasePrice()
-}
\ No newline at end of file
+		if policy.DailyCap > 0 && amount > policy.DailyCap {
+			fee.add(fmt.Sprintf("Day %d: daily cap %d", d, policy.DailyCap), policy.DailyCap-amount)
+		}
+	}
+
+	if fee.Total < policy.MinimumCharge {
+		fee.add("Minimum charge", policy.MinimumCharge-fee.Total)
+	}
+	return fee
+}
+
+func roundUnits(d time.Duration, unit time.Duration, rounding Rounding) int64 {
+	units := int64(d / unit)
+	rest := d % unit
+	switch rounding {
+	case RoundUp:
+		if rest > 0 {
+			units++
+		}
+	case RoundNearest:
+		if rest*2 >= unit {
+			units++
+		}
+	}
+	return units
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// stayOf is a closed Car ticket of the given length
+func stayOf(d time.Duration) ParkingTicket {
+	checkin := time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC)
+	return ParkingTicket{
+		VehicleParked: &Vehicle{RegistrationNumber: "KA-01-0001", Type: Car},
+		BilledAs:      Car,
+		CheckinTime:   checkin.UnixNano(),
+		CheckoutTime:  checkin.Add(d).UnixNano(),
+	}
+}
+
+func TestMeteredPricing(t *testing.T) {
+	hourly := BillingPolicy{Unit: time.Hour, Rounding: RoundUp}
+	quarters := BillingPolicy{Unit: 15 * time.Minute, Rounding: RoundUp}
+	tests := []struct {
+		name   string
+		policy BillingPolicy
+		rates  map[VehicleType]int
+		stay   time.Duration
+		want   int
+	}{
+		{"started hour", hourly, nil, 61 * time.Minute, 40},
+		{"default unit is the hour", BillingPolicy{}, nil, 10 * time.Minute, 20},
+		{"within the grace period", BillingPolicy{Unit: time.Hour, GracePeriod: 15 * time.Minute}, nil, 15 * time.Minute, 0},
+		{"grace period taken off", BillingPolicy{Unit: time.Hour, GracePeriod: 15 * time.Minute}, nil, 75*time.Minute + time.Second, 40},
+		{"15 minute blocks", quarters, nil, 31 * time.Minute, 15},
+		{"15 minute blocks, exact", quarters, nil, 45 * time.Minute, 15},
+		{"15 minute blocks, started", quarters, nil, 46 * time.Minute, 20},
+		{"round nearest, below half", BillingPolicy{Unit: time.Hour, Rounding: RoundNearest}, nil, 89 * time.Minute, 20},
+		{"round nearest, half", BillingPolicy{Unit: time.Hour, Rounding: RoundNearest}, nil, 90 * time.Minute, 40},
+		{"round down", BillingPolicy{Unit: time.Hour, Rounding: RoundDown}, nil, 119 * time.Minute, 20},
+		{"minimum charge", BillingPolicy{Unit: time.Hour, MinimumCharge: 50}, nil, 30 * time.Minute, 50},
+		{"minimum charge below the fee", BillingPolicy{Unit: time.Hour, MinimumCharge: 50}, nil, 3 * time.Hour, 60},
+		{"no minimum within the grace period", BillingPolicy{Unit: time.Hour, GracePeriod: 10 * time.Minute, MinimumCharge: 50}, nil, 5 * time.Minute, 0},
+		{"daily cap", BillingPolicy{Unit: time.Hour, DailyCap: 100}, nil, 10 * time.Hour, 100},
+		{"custom rate", hourly, map[VehicleType]int{Car: 30}, 2 * time.Hour, 60},
+		{"rate of another type", hourly, map[VehicleType]int{Truck: 50}, 2 * time.Hour, 40},
+	}
+	for _, tt := range tests {
+		pricing := &MeteredPricing{Policy: tt.policy, Rates: tt.rates}
+		fee := pricing.CalculatePrice(stayOf(tt.stay))
+		if fee.Total != tt.want {
+			t.Errorf("%s: fee %d (%+v), want %d", tt.name, fee.Total, fee.Lines, tt.want)
+		}
+		sum := 0
+		for _, line := range fee.Lines {
+			sum += line.Amount
+		}
+		if sum != fee.Total {
+			t.Errorf("%s: lines sum to %d, total %d", tt.name, sum, fee.Total)
+		}
+	}
+}
+
+func TestNormalPricingBillsStartedHours(t *testing.T) {
+	fee := (&NormalPricing{}).CalculatePrice(stayOf(2*time.Hour + time.Second))
+	if fee.Total != 3*Car.BasePrice() {
+		t.Fatalf("fee %d (%+v), want %d", fee.Total, fee.Lines, 3*Car.BasePrice())
+	}
+}
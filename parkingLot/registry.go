@@ -0,0 +1,130 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var (
+	ErrLotNotFound  = errors.New("parking lot not found")
+	ErrDuplicateLot = errors.New("parking lot already registered")
+	ErrInvalidLotID = errors.New("invalid parking lot id")
+)
+
+// TicketRef is what a ticket id encodes : <lotId>_<floor>_<slot>_<visit>, e.g. PR123_2_5_17.
+// Visit numbers the tickets issued by the lot, so every visit of a slot gets its own id
+type TicketRef struct {
+	LotId   string
+	FloorId int
+	SlotId  int
+	Visit   uint64
+}
+
+func (t TicketRef) String() string {
+	return t.LotId + "_" + strconv.Itoa(t.FloorId) + "_" + strconv.Itoa(t.SlotId) + "_" + strconv.FormatUint(t.Visit, 10)
+}
+
+// ParseTicketID splits a ticket id, the lot id may itself contain underscores
+func ParseTicketID(ticketID string) (TicketRef, error) {
+	parts := strings.Split(ticketID, "_")
+	if len(parts) < 4 {
+		return TicketRef{}, fmt.Errorf("%w: %s", ErrTicketNotFound, ticketID)
+	}
+	n := len(parts)
+	floor, errFloor := strconv.Atoi(parts[n-3])
+	slot, errSlot := strconv.Atoi(parts[n-2])
+	visit, errVisit := strconv.ParseUint(parts[n-1], 10, 64)
+	lotID := strings.Join(parts[:n-3], "_")
+	if errFloor != nil || errSlot != nil || errVisit != nil || lotID == "" {
+		return TicketRef{}, fmt.Errorf("%w: %s", ErrTicketNotFound, ticketID)
+	}
+	return TicketRef{LotId: lotID, FloorId: floor, SlotId: slot, Visit: visit}, nil
+}
+
+func validateLotID(id string) error {
+	if id == "" || strings.ContainsFunc(id, func(r rune) bool { return r == ' ' || r == '\t' || r == '\n' }) {
+		return fmt.Errorf("%w: %q", ErrInvalidLotID, id)
+	}
+	return nil
+}
+
+// LotRegistry manages the lots of one company, tickets are routed to their lot through the id
+type LotRegistry struct {
+	mu   sync.RWMutex
+	lots map[string]*ParkingLot
+}
+
+func NewLotRegistry() *LotRegistry {
+	return &LotRegistry{lots: make(map[string]*ParkingLot)}
+}
+
+// Register adds pl, lot ids have to be unique within the registry
+func (r *LotRegistry) Register(pl *ParkingLot) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, exists := r.lots[pl.ID()]; exists {
+		return fmt.Errorf("%w: %s", ErrDuplicateLot, pl.ID())
+	}
+	r.lots[pl.ID()] = pl
+	return nil
+}
+
+// Remove drops the lot, its tickets can not be resolved anymore
+func (r *LotRegistry) Remove(lotID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.lots, lotID)
+}
+
+func (r *LotRegistry) Get(lotID string) (*ParkingLot, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	pl, exists := r.lots[lotID]
+	if !exists {
+		return nil, fmt.Errorf("%w: %s", ErrLotNotFound, lotID)
+	}
+	return pl, nil
+}
+
+// IDs returns the registered lot ids, sorted
+func (r *LotRegistry) IDs() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	ids := make([]string, 0, len(r.lots))
+	for id := range r.lots {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+// ResolveTicket returns the lot that issued ticketID
+func (r *LotRegistry) ResolveTicket(ticketID string) (*ParkingLot, error) {
+	ref, err := ParseTicketID(ticketID)
+	if err != nil {
+		return nil, err
+	}
+	return r.Get(ref.LotId)
+}
+
+// Unpark routes the ticket to its lot
+func (r *LotRegistry) Unpark(ticketID string) (Receipt, error) {
+	pl, err := r.ResolveTicket(ticketID)
+	if err != nil {
+		return Receipt{}, err
+	}
+	return pl.Unpark(ticketID)
+}
+
+// GetTicket routes the lookup to the lot of the ticket
+func (r *LotRegistry) GetTicket(ticketID string) (ParkingTicket, error) {
+	pl, err := r.ResolveTicket(ticketID)
+	if err != nil {
+		return ParkingTicket{}, err
+	}
+	return pl.GetTicket(ticketID)
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseTicketID(t *testing.T) {
+	for _, ref := range []TicketRef{
+		{LotId: "PR123", FloorId: 2, SlotId: 5, Visit: 1},
+		{LotId: "NORTH_SITE", FloorId: 0, SlotId: 11, Visit: 42},
+	} {
+		got, err := ParseTicketID(ref.String())
+		if err != nil || got != ref {
+			t.Errorf("ParseTicketID(%s) = %+v, %v, want %+v", ref.String(), got, err, ref)
+		}
+	}
+	for _, id := range []string{"", "PR123", "PR123_2_5", "_2_5_1", "PR123_x_5_1", "PR123_2_y_1", "PR123_2_5_-1"} {
+		if _, err := ParseTicketID(id); !errors.Is(err, ErrTicketNotFound) {
+			t.Errorf("ParseTicketID(%q) err = %v, want ErrTicketNotFound", id, err)
+		}
+	}
+}
+
+func TestLotRegistryRoutesTickets(t *testing.T) {
+	registry := NewLotRegistry()
+	lots := make(map[string]*ParkingLot)
+	for _, id := range []string{"PR123", "NORTH_SITE"} {
+		lot, err := NewParkingLot(DefaultLayout(1, 6), &NormalPricing{}, WithID(id))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := registry.Register(lot); err != nil {
+			t.Fatal(err)
+		}
+		lots[id] = lot
+	}
+	if err := registry.Register(lots["PR123"]); !errors.Is(err, ErrDuplicateLot) {
+		t.Fatalf("second Register err = %v, want ErrDuplicateLot", err)
+	}
+	if _, err := NewParkingLot(DefaultLayout(1, 6), &NormalPricing{}, WithID("PR 123")); !errors.Is(err, ErrInvalidLotID) {
+		t.Fatalf("NewParkingLot with a blank in the id err = %v, want ErrInvalidLotID", err)
+	}
+	if ids := registry.IDs(); len(ids) != 2 || ids[0] != "NORTH_SITE" || ids[1] != "PR123" {
+		t.Fatalf("IDs() = %v", ids)
+	}
+
+	ticket, err := lots["NORTH_SITE"].CheckIn(Vehicle{RegistrationNumber: "KA-01-0001", Type: Car})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lot, err := registry.ResolveTicket(ticket.Id); err != nil || lot != lots["NORTH_SITE"] {
+		t.Fatalf("ResolveTicket(%s) = %v, %v", ticket.Id, lot, err)
+	}
+	if got, err := registry.GetTicket(ticket.Id); err != nil || got.Id != ticket.Id {
+		t.Fatalf("GetTicket(%s) = %+v, %v", ticket.Id, got, err)
+	}
+	if _, err := registry.Unpark(ticket.Id); err != nil {
+		t.Fatal(err)
+	}
+
+	registry.Remove("NORTH_SITE")
+	if _, err := registry.GetTicket(ticket.Id); !errors.Is(err, ErrLotNotFound) {
+		t.Fatalf("GetTicket after Remove err = %v, want ErrLotNotFound", err)
+	}
+}
+
+func TestTicketIDFormat(t *testing.T) {
+	registry := NewLotRegistry()
+	for _, id := range []string{"PR123", "NORTH_SITE"} {
+		lot, err := NewParkingLot(DefaultLayout(3, 6), &NormalPricing{}, WithID(id))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := registry.Register(lot); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	lot, _ := registry.Get("NORTH_SITE")
+	ticket, err := lot.CheckIn(Vehicle{RegistrationNumber: "KA-01-0001", Type: Truck})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ticket.Id != "NORTH_SITE_0_1_1" {
+		t.Fatalf("ticket id %s, want NORTH_SITE_0_1_1", ticket.Id)
+	}
+	ref, err := ParseTicketID(ticket.Id)
+	if err != nil || ref != (TicketRef{LotId: "NORTH_SITE", FloorId: 0, SlotId: 1, Visit: 1}) {
+		t.Fatalf("ParseTicketID(%s) = %+v, %v", ticket.Id, ref, err)
+	}
+	if _, err := registry.Unpark(ticket.Id); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := registry.Unpark("PR123_2_5_1"); err == nil {
+		t.Fatal("unpark of a ticket never issued succeeded")
+	}
+}
+
+func TestSlotReuseKeepsHistory(t *testing.T) {
+	clock := NewFakeClock(time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC))
+	newLot := func() *ParkingLot {
+		lot, err := NewParkingLot(Layout{Floors: []FloorLayout{UniformFloor(Car, 1)}}, &NormalPricing{Clock: clock},
+			WithID("PR123"), WithClock(clock))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return lot
+	}
+	lot := newLot()
+	registrations := []string{"KA-01-0001", "KA-01-0002"}
+	var ids []string
+	for _, registration := range registrations {
+		ticket, err := lot.CheckIn(Vehicle{RegistrationNumber: registration, Type: Car})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, ticket.Id)
+		clock.Advance(time.Hour)
+		if _, err := lot.Unpark(ticket.Id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if ids[0] != "PR123_0_0_1" || ids[1] != "PR123_0_0_2" {
+		t.Fatalf("ticket ids %v, want PR123_0_0_1 and PR123_0_0_2", ids)
+	}
+
+	// every visit keeps resolving to its own ticket, also across a snapshot
+	var buf bytes.Buffer
+	if err := lot.Snapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+	restored := newLot()
+	if err := restored.Restore(&buf); err != nil {
+		t.Fatal(err)
+	}
+	for _, pl := range []*ParkingLot{lot, restored} {
+		for i, id := range ids {
+			ticket, err := pl.GetTicket(id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ticket.Visit != uint64(i+1) || ticket.VehicleParked.RegistrationNumber != registrations[i] || ticket.Status != TicketClosed {
+				t.Fatalf("%s resolves to visit %d of %s", id, ticket.Visit, ticket.VehicleParked.RegistrationNumber)
+			}
+		}
+		history := pl.History(time.Time{}, clock.Now().Add(time.Second))
+		if len(history) != 2 || history[0].Id != ids[0] || history[1].Id != ids[1] {
+			t.Fatalf("history %+v, want %v", history, ids)
+		}
+	}
+
+	// the next visit is numbered after the restored ones
+	ticket, err := restored.CheckIn(Vehicle{RegistrationNumber: "KA-01-0003", Type: Car})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ticket.Id != "PR123_0_0_3" {
+		t.Fatalf("ticket id %s after restore, want PR123_0_0_3", ticket.Id)
+	}
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"encoding/csv"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// recordClosed appends the ticket to the history, the pool lock of the ticket has to be held
+func (pl *ParkingLot) recordClosed(ticket *ParkingTicket) {
+	pl.historyLock.Lock()
+	defer pl.historyLock.Unlock()
+	pl.history = append(pl.history, ticket)
+}
+
+// historyOf sorts closed tickets by checkout time into a history
+func historyOf(closed []*ParkingTicket) []*ParkingTicket {
+	history := slices.Clone(closed)
+	slices.SortFunc(history, func(a *ParkingTicket, b *ParkingTicket) int {
+		if a.CheckoutTime != b.CheckoutTime {
+			return compareInt64(a.CheckoutTime, b.CheckoutTime)
+		}
+		return compareInt64(int64(a.Visit), int64(b.Visit))
+	})
+	return history
+}
+
+func compareInt64(a int64, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// History returns copies of the tickets closed in [from, to), by checkout time
+func (pl *ParkingLot) History(from time.Time, to time.Time) []ParkingTicket {
+	pl.historyLock.RLock()
+	defer pl.historyLock.RUnlock()
+	closed := make([]ParkingTicket, 0)
+	for _, ticket := range pl.history {
+		checkout := time.Unix(0, ticket.CheckoutTime)
+		if !checkout.Before(from) && checkout.Before(to) {
+			closed = append(closed, *ticket)
+		}
+	}
+	return closed
+}
+
+// RevenueReport sums the fees of the tickets closed in [From, To), revenue is counted on the checkout day.
+// No-show fees of the reservations released in [From, To) are reported apart, they are not in Total
+type RevenueReport struct {
+	From    time.Time
+	To      time.Time
+	Tickets int
+	Total   int
+	// Refunds & Adjustments sum the ledger entries of these tickets, whenever they were made
+	Refunds     int
+	Adjustments int
+	// ByDay is keyed by the checkout date (2006-01-02) in the location of From
+	ByDay         map[string]int
+	ByVehicleType map[VehicleType]int
+	ByFloor       map[int]int
+	AverageStay   time.Duration
+	NoShows       int
+	NoShowFees    int
+}
+
+// Revenue reports the tickets closed in [from, to)
+func (pl *ParkingLot) Revenue(from time.Time, to time.Time) RevenueReport {
+	report := RevenueReport{
+		From:          from,
+		To:            to,
+		ByDay:         make(map[string]int),
+		ByVehicleType: make(map[VehicleType]int),
+		ByFloor:       make(map[int]int),
+	}
+	var stay time.Duration
+	for _, ticket := range pl.History(from, to) {
+		fee := ticket.Fee.Total
+		report.Tickets++
+		report.Total += fee
+		report.ByDay[time.Unix(0, ticket.CheckoutTime).In(from.Location()).Format(time.DateOnly)] += fee
+		report.ByVehicleType[ticket.VehicleParked.Type] += fee
+		report.ByFloor[ticket.SlotDetails.FloorId] += fee
+		stay += time.Duration(ticket.CheckoutTime - ticket.CheckinTime)
+		for _, tx := range ticket.Payments {
+			switch tx.Kind {
+			case TxRefund:
+				report.Refunds += tx.Amount
+			case TxAdjustment:
+				report.Adjustments += tx.Amount
+			}
+		}
+	}
+	if report.Tickets > 0 {
+		report.AverageStay = stay / time.Duration(report.Tickets)
+	}
+
+	pl.reservationLock.Lock()
+	defer pl.reservationLock.Unlock()
+	for _, r := range pl.reservations {
+		if r.Status == ReservationNoShow && !r.NoShowTime.Before(from) && r.NoShowTime.Before(to) {
+			report.NoShows++
+			report.NoShowFees += r.NoShowFee.Total
+		}
+	}
+	return report
+}
+
+// HourlyOccupancy is the most slots occupied at once during the hour starting at Hour
+type HourlyOccupancy struct {
+	Hour time.Time
+	Peak int
+}
+
+// PeakOccupancy returns one entry per hour of [from, to), from is truncated to the hour.
+// Closed and active tickets both count, a vehicle taking several slots counts for each of them
+func (pl *ParkingLot) PeakOccupancy(from time.Time, to time.Time) []HourlyOccupancy {
+	type change struct {
+		at    int64
+		delta int
+	}
+	changes := make([]change, 0)
+	// the pool locks keep tickets from closing meanwhile : every ticket is read once, active or in the history
+	pl.rlockAll()
+	pl.ticketLock.RLock()
+	for _, ticket := range pl.ticketStore {
+		if ticket.Status != TicketClosed {
+			changes = append(changes, change{ticket.CheckinTime, len(ticket.occupiedSlots())})
+		}
+	}
+	pl.ticketLock.RUnlock()
+	pl.historyLock.RLock()
+	for _, ticket := range pl.history {
+		slots := len(ticket.occupiedSlots())
+		changes = append(changes, change{ticket.CheckinTime, slots}, change{ticket.CheckoutTime, -slots})
+	}
+	pl.historyLock.RUnlock()
+	pl.runlockAll()
+	// at the same instant departures go first, a slot handed over is not counted twice
+	slices.SortFunc(changes, func(a change, b change) int {
+		if a.at != b.at {
+			return compareInt64(a.at, b.at)
+		}
+		return a.delta - b.delta
+	})
+
+	hours := make([]HourlyOccupancy, 0)
+	occupied, i := 0, 0
+	for hour := from.Truncate(time.Hour); hour.Before(to); hour = hour.Add(time.Hour) {
+		start, end := hour.UnixNano(), hour.Add(time.Hour).UnixNano()
+		// level carried into the hour
+		for ; i < len(changes) && changes[i].at < start; i++ {
+			occupied += changes[i].delta
+		}
+		peak := occupied
+		for ; i < len(changes) && changes[i].at < end; i++ {
+			occupied += changes[i].delta
+			peak = max(peak, occupied)
+		}
+		hours = append(hours, HourlyOccupancy{Hour: hour, Peak: peak})
+	}
+	return hours
+}
+
+var historyCSVHeader = []string{
+	"ticket_id", "visit", "registration_number", "vehicle_type", "billed_as", "floor", "slots",
+	"checkin", "checkout", "stay_minutes", "energy_kwh", "coupon", "discount", "fee",
+	"paid", "refunded", "balance",
+}
+
+// ExportCSV writes the tickets closed in [from, to) as CSV, one line per ticket after the header
+func (pl *ParkingLot) ExportCSV(w io.Writer, from time.Time, to time.Time) error {
+	out := csv.NewWriter(w)
+	if err := out.Write(historyCSVHeader); err != nil {
+		return err
+	}
+	for _, ticket := range pl.History(from, to) {
+		slotIds := make([]string, 0)
+		for _, slot := range ticket.occupiedSlots() {
+			slotIds = append(slotIds, strconv.Itoa(slot.Id))
+		}
+		discount := 0
+		for _, line := range ticket.Fee.Discounts() {
+			discount -= line.Amount
+		}
+		paid, refunded := 0, 0
+		for _, tx := range ticket.Payments {
+			switch tx.Kind {
+			case TxPayment:
+				paid += tx.Amount
+			case TxRefund:
+				refunded += tx.Amount
+			}
+		}
+		checkin, checkout := time.Unix(0, ticket.CheckinTime).UTC(), time.Unix(0, ticket.CheckoutTime).UTC()
+		err := out.Write([]string{
+			ticket.Id,
+			strconv.FormatUint(ticket.Visit, 10),
+			ticket.VehicleParked.RegistrationNumber,
+			ticket.VehicleParked.Type.ToString(),
+			ticket.BilledAs.ToString(),
+			strconv.Itoa(ticket.SlotDetails.FloorId),
+			strings.Join(slotIds, " "),
+			checkin.Format(time.RFC3339),
+			checkout.Format(time.RFC3339),
+			strconv.FormatFloat(checkout.Sub(checkin).Minutes(), 'f', 0, 64),
+			strconv.FormatFloat(ticket.EnergyKWh, 'f', -1, 64),
+			ticket.Coupon,
+			strconv.Itoa(discount),
+			strconv.Itoa(ticket.Fee.Total),
+			strconv.Itoa(paid),
+			strconv.Itoa(refunded),
+			strconv.Itoa(ticket.balance()),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	out.Flush()
+	return out.Error()
+}
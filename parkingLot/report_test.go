@@ -0,0 +1,203 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// reportLot has 2 floors of 2 Car slots and a Truck slot, clock at Monday 2024-03-04 09:00 UTC
+func reportLot(t *testing.T) (*ParkingLot, *FakeClock) {
+	t.Helper()
+	clock := NewFakeClock(time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC))
+	floor := FloorLayout{Slots: []SlotSpec{{Type: Car}, {Type: Car}, {Type: Truck}}}
+	lot, err := NewParkingLot(Layout{Floors: []FloorLayout{floor, floor}}, &NormalPricing{Clock: clock}, WithClock(clock))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return lot, clock
+}
+
+func TestRevenueAndHistory(t *testing.T) {
+	lot, clock := reportLot(t)
+	start := clock.Now()
+	car, _ := lot.CheckIn(Vehicle{RegistrationNumber: "KA-01-0001", Type: Car})
+	truck, _ := lot.CheckIn(Vehicle{RegistrationNumber: "KA-01-0002", Type: Truck})
+	active, _ := lot.CheckIn(Vehicle{RegistrationNumber: "KA-01-0003", Type: Car})
+	clock.Advance(2 * time.Hour)
+	if _, err := lot.Unpark(car.Id); err != nil {
+		t.Fatal(err)
+	}
+	// the truck leaves the next day
+	clock.Advance(24 * time.Hour)
+	if _, err := lot.Unpark(truck.Id); err != nil {
+		t.Fatal(err)
+	}
+
+	history := lot.History(start, clock.Now().Add(time.Second))
+	if len(history) != 2 || history[0].Id != car.Id || history[1].Id != truck.Id {
+		t.Fatalf("history %+v, want the car then the truck", history)
+	}
+	if firstDay := lot.History(start, start.Add(24*time.Hour)); len(firstDay) != 1 {
+		t.Fatalf("first day history has %d tickets, want 1", len(firstDay))
+	}
+
+	report := lot.Revenue(start, clock.Now().Add(time.Second))
+	truckFee := 26 * Truck.BasePrice()
+	if report.Tickets != 2 || report.Total != 40+truckFee {
+		t.Fatalf("revenue %+v", report)
+	}
+	if report.ByDay["2024-03-04"] != 40 || report.ByDay["2024-03-05"] != truckFee {
+		t.Fatalf("revenue by day %v", report.ByDay)
+	}
+	if report.ByVehicleType[Car] != 40 || report.ByVehicleType[Truck] != truckFee || report.ByFloor[0] != 40+truckFee {
+		t.Fatalf("revenue by type %v, by floor %v", report.ByVehicleType, report.ByFloor)
+	}
+	if report.AverageStay != 14*time.Hour {
+		t.Fatalf("average stay %v, want 14h", report.AverageStay)
+	}
+	// active tickets are not revenue yet
+	for _, ticket := range history {
+		if ticket.Id == active.Id {
+			t.Fatalf("active ticket %s in the history", active.Id)
+		}
+	}
+}
+
+func TestPeakOccupancy(t *testing.T) {
+	lot, clock := reportLot(t)
+	start := clock.Now()
+	first, _ := lot.CheckIn(Vehicle{RegistrationNumber: "KA-01-0001", Type: Car})
+	clock.Advance(30 * time.Minute)
+	lot.CheckIn(Vehicle{RegistrationNumber: "KA-01-0002", Type: Car})
+	clock.Advance(15 * time.Minute)
+	lot.Unpark(first.Id)
+	clock.Advance(90 * time.Minute)
+	lot.CheckIn(Vehicle{RegistrationNumber: "KA-01-0003", Type: Truck})
+
+	hours := lot.PeakOccupancy(start.Add(10*time.Minute), start.Add(3*time.Hour))
+	want := []int{2, 1, 2}
+	if len(hours) != len(want) {
+		t.Fatalf("%d hours, want %d", len(hours), len(want))
+	}
+	for i, hour := range hours {
+		if !hour.Hour.Equal(start.Add(time.Duration(i)*time.Hour)) || hour.Peak != want[i] {
+			t.Errorf("hour %d: %v peak %d, want %v peak %d", i, hour.Hour, hour.Peak, start.Add(time.Duration(i)*time.Hour), want[i])
+		}
+	}
+}
+
+func TestExportCSV(t *testing.T) {
+	lot, clock := reportLot(t)
+	start := clock.Now()
+	ticket, _ := lot.CheckIn(Vehicle{RegistrationNumber: "KA-01-0001", Type: Car})
+	clock.Advance(90 * time.Minute)
+	if _, err := lot.Unpark(ticket.Id); err != nil {
+		t.Fatal(err)
+	}
+
+	var out strings.Builder
+	if err := lot.ExportCSV(&out, start, clock.Now().Add(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := csv.NewReader(strings.NewReader(out.String())).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 || strings.Join(rows[0], ",") != strings.Join(historyCSVHeader, ",") {
+		t.Fatalf("csv:\n%s", out.String())
+	}
+	want := []string{ticket.Id, "1", "KA-01-0001", "Car", "Car", "0", "0",
+		"2024-03-04T09:00:00Z", "2024-03-04T10:30:00Z", "90", "0", "", "0", "40", "0", "0", "40"}
+	if strings.Join(rows[1], ",") != strings.Join(want, ",") {
+		t.Fatalf("row %v, want %v", rows[1], want)
+	}
+}
+
+func TestPeakOccupancyCountsReusedSlots(t *testing.T) {
+	start := time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+	lot, err := NewParkingLot(Layout{Floors: []FloorLayout{UniformFloor(Car, 2)}}, &NormalPricing{Clock: clock}, WithClock(clock))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkIn := func(registration string) ParkingTicket {
+		ticket, err := lot.CheckIn(Vehicle{RegistrationNumber: registration, Type: Car})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ticket
+	}
+	unpark := func(ticket ParkingTicket) {
+		if _, err := lot.Unpark(ticket.Id); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	first := checkIn("KA-01-0001") // 09:00 - 10:30 on slot 0
+	clock.Set(start.Add(15 * time.Minute))
+	second := checkIn("KA-01-0002") // 09:15 - 09:45 on slot 1
+	clock.Set(start.Add(45 * time.Minute))
+	unpark(second)
+	clock.Set(start.Add(90 * time.Minute))
+	unpark(first)
+	// slot 0 again, this visit gets its own id
+	clock.Set(start.Add(105 * time.Minute))
+	if again := checkIn("KA-01-0003"); again.SlotDetails.Id != first.SlotDetails.Id || again.Id == first.Id {
+		t.Fatalf("reused slot ticket %s on slot %d, first visit %s", again.Id, again.SlotDetails.Id, first.Id)
+	}
+
+	hours := lot.PeakOccupancy(start, start.Add(3*time.Hour))
+	want := []int{2, 1, 1}
+	if len(hours) != len(want) {
+		t.Fatalf("%d hours, want %d", len(hours), len(want))
+	}
+	for i, hour := range hours {
+		if hour.Peak != want[i] {
+			t.Fatalf("peak of %s = %d, want %d (%+v)", hour.Hour.Format("15:04"), hour.Peak, want[i], hours)
+		}
+	}
+}
+
+// PeakOccupancy reads tickets other pools are closing, go test -race
+func TestPeakOccupancyDuringTraffic(t *testing.T) {
+	lot, err := NewParkingLot(DefaultLayout(2, 8), &NormalPricing{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	from := time.Now().Add(-time.Hour)
+
+	var wg sync.WaitGroup
+	for i, vtype := range []VehicleType{Bike, Car, Truck} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for n := range 100 {
+				ticket, err := lot.CheckIn(Vehicle{RegistrationNumber: fmt.Sprintf("KA-%d-%d", i, n), Type: vtype})
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if _, err := lot.Unpark(ticket.Id); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for range 50 {
+			for _, hour := range lot.PeakOccupancy(from, time.Now().Add(time.Hour)) {
+				if hour.Peak < 0 || hour.Peak > 16 {
+					t.Errorf("peak %d of a 16 slot lot", hour.Peak)
+				}
+			}
+		}
+	}()
+	wg.Wait()
+}
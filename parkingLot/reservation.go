@@ -0,0 +1,430 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrReservationNotFound   = errors.New("reservation not found")
+	ErrReservationNotStarted = errors.New("reservation window has not started")
+	ErrReservationClosed     = errors.New("reservation is no longer valid")
+	ErrNoCapacity            = errors.New("no capacity for the reservation window")
+)
+
+type ReservationStatus int
+
+const (
+	// ReservationPending -> window not started, or no slot could be held yet
+	ReservationPending ReservationStatus = iota
+	// ReservationHeld -> a slot is kept out of the availability index for the customer
+	ReservationHeld
+	// ReservationFulfilled -> the customer checked in
+	ReservationFulfilled
+	// ReservationNoShow -> the customer did not come within the grace period, the no-show fee is due
+	// and counted in the revenue report
+	ReservationNoShow
+	ReservationCancelled
+	// ReservationExpired -> the window ended before any slot could be held, nothing is due
+	ReservationExpired
+)
+
+func (s ReservationStatus) ToString() string {
+	switch s {
+	case ReservationPending:
+		return "PENDING"
+	case ReservationHeld:
+		return "HELD"
+	case ReservationFulfilled:
+		return "FULFILLED"
+	case ReservationNoShow:
+		return "NO_SHOW"
+	case ReservationCancelled:
+		return "CANCELLED"
+	case ReservationExpired:
+		return "EXPIRED"
+	default:
+		return ""
+	}
+}
+
+// open -> the reservation still counts against the capacity of its window
+func (s ReservationStatus) open() bool {
+	return s == ReservationPending || s == ReservationHeld
+}
+
+type Reservation struct {
+	Id          string
+	VehicleType VehicleType
+	From        time.Time
+	To          time.Time
+	Status      ReservationStatus
+	// Slot is set while the reservation is held
+	Slot *Slot
+	// TicketId is set once fulfilled
+	TicketId string
+	// NoShowFee & NoShowTime are set once the reservation is a no-show
+	NoShowFee  Fee
+	NoShowTime time.Time
+}
+
+// NoShowPricing is implemented by pricings charging a custom no-show fee,
+// other pricings charge one hour at the base price of the vehicle type
+type NoShowPricing interface {
+	NoShowFee(reservation Reservation) Fee
+}
+
+func (ps *SurgePricing) NoShowFee(reservation Reservation) Fee {
+	return noShowFee(ps.Base, reservation)
+}
+
+func noShowFee(ps Pricing, reservation Reservation) Fee {
+	if custom, ok := ps.(NoShowPricing); ok {
+		return custom.NoShowFee(reservation)
+	}
+	var fee Fee
+	fee.add("No-show: 1h @ "+strconv.Itoa(reservation.VehicleType.BasePrice())+"/h", reservation.VehicleType.BasePrice())
+	return fee
+}
+
+// DefaultNoShowGrace is how long a held slot waits for its customer
+const DefaultNoShowGrace = 30 * time.Minute
+
+// WithNoShowGrace sets how long after the window start a held slot is kept for a customer who has not arrived
+func WithNoShowGrace(grace time.Duration) Option {
+	return func(pl *ParkingLot) {
+		pl.noShowGrace = grace
+	}
+}
+
+// Reserve books a slot of vtype for [from, to). The slot is taken out of the availability index
+// when the window starts, a reservation can be booked as long as the overlapping open reservations
+// leave room in the pool.
+// Reservations are best effort : vehicles parked without one are not counted, if walk ins fill the pool
+// the reservation stays pending until a slot frees up, and expires with nothing due if none does
+func (pl *ParkingLot) Reserve(vtype VehicleType, from time.Time, to time.Time) (Reservation, error) {
+	if !from.Before(to) {
+		return Reservation{}, fmt.Errorf("invalid reservation window %v - %v", from, to)
+	}
+	if vtype.SlotsRequired() > 1 {
+		return Reservation{}, fmt.Errorf("%s can not be reserved", vtype.ToString())
+	}
+	if !to.After(pl.clock.Now()) {
+		return Reservation{}, fmt.Errorf("reservation window already over")
+	}
+	pool := vtype.SlotType()
+
+	pl.lockAll()
+	defer pl.unlockAll()
+	pl.reservationLock.Lock()
+	defer pl.reservationLock.Unlock()
+
+	overlapping := 0
+	for _, r := range pl.reservations {
+		if r.Status.open() && r.VehicleType.SlotType() == pool && r.From.Before(to) && from.Before(r.To) {
+			overlapping++
+		}
+	}
+	if overlapping >= pl.slotCount[pool] {
+		return Reservation{}, fmt.Errorf("%w: %s", ErrNoCapacity, vtype.ToString())
+	}
+
+	reservation := &Reservation{
+		Id:          pl.id + "_R" + strconv.FormatUint(pl.reservationSeq+1, 10),
+		VehicleType: vtype,
+		From:        from,
+		To:          to,
+	}
+	err := pl.journalEvent(JournalEvent{
+		Kind:          EventReserved,
+		Time:          pl.clock.Now().UnixNano(),
+		ReservationId: reservation.Id,
+		VehicleType:   &vtype,
+		From:          from.UnixNano(),
+		To:            to.UnixNano(),
+	})
+	if err != nil {
+		return Reservation{}, err
+	}
+	pl.reservationSeq++
+	pl.reservations[reservation.Id] = reservation
+	pl.processReservationsLocked()
+	return *reservation, nil
+}
+
+// GetReservation returns a copy of the reservation
+func (pl *ParkingLot) GetReservation(reservationID string) (Reservation, error) {
+	pl.reservationLock.Lock()
+	defer pl.reservationLock.Unlock()
+	reservation, exists := pl.reservations[reservationID]
+	if !exists {
+		return Reservation{}, fmt.Errorf("%w: %s", ErrReservationNotFound, reservationID)
+	}
+	return *reservation, nil
+}
+
+// CancelReservation releases the held slot, if any
+func (pl *ParkingLot) CancelReservation(reservationID string) error {
+	pl.lockAll()
+	defer pl.unlockAll()
+	pl.reservationLock.Lock()
+	defer pl.reservationLock.Unlock()
+
+	reservation, exists := pl.reservations[reservationID]
+	if !exists {
+		return fmt.Errorf("%w: %s", ErrReservationNotFound, reservationID)
+	}
+	if !reservation.Status.open() {
+		return fmt.Errorf("%w: %s is %s", ErrReservationClosed, reservationID, reservation.Status.ToString())
+	}
+	err := pl.journalEvent(JournalEvent{
+		Kind:          EventReservationCancelled,
+		Time:          pl.clock.Now().UnixNano(),
+		ReservationId: reservationID,
+	})
+	if err != nil {
+		return err
+	}
+	pl.releaseHold(reservation)
+	reservation.Status = ReservationCancelled
+	return nil
+}
+
+// CheckInReservation parks vehicle on the slot held for the reservation
+func (pl *ParkingLot) CheckInReservation(reservationID string, vehicle Vehicle) (ParkingTicket, error) {
+	pl.lockAll()
+	defer pl.unlockAll()
+	pl.reservationLock.Lock()
+	defer pl.reservationLock.Unlock()
+
+	pl.processReservationsLocked()
+	reservation, exists := pl.reservations[reservationID]
+	if !exists {
+		return ParkingTicket{}, fmt.Errorf("%w: %s", ErrReservationNotFound, reservationID)
+	}
+	if vehicle.Type != reservation.VehicleType {
+		return ParkingTicket{}, fmt.Errorf("reservation %s is for a %s", reservationID, reservation.VehicleType.ToString())
+	}
+	switch reservation.Status {
+	case ReservationHeld:
+	case ReservationPending:
+		if pl.clock.Now().Before(reservation.From) {
+			return ParkingTicket{}, fmt.Errorf("%w: %s starts at %v", ErrReservationNotStarted, reservationID, reservation.From)
+		}
+		return ParkingTicket{}, fmt.Errorf("%w for reservation %s", ErrNoSlotAvailable, reservationID)
+	default:
+		return ParkingTicket{}, fmt.Errorf("%w: %s is %s", ErrReservationClosed, reservationID, reservation.Status.ToString())
+	}
+
+	pool := reservation.Slot.GetVehicleType()
+	ticket, err := pl.issueTicket(vehicle, []*Slot{reservation.Slot}, len(pl.freeSlots(pool)), reservationID)
+	if err != nil {
+		return ParkingTicket{}, err
+	}
+	reservation.Status = ReservationFulfilled
+	reservation.TicketId = ticket.Id
+	reservation.Slot = nil
+	return ticket, nil
+}
+
+// ProcessReservations holds slots for the windows that started and releases the no-shows,
+// it returns the reservations that became no-shows. Check ins run it too, call it periodically
+// so holds and releases happen on time when the lot is quiet
+func (pl *ParkingLot) ProcessReservations() []Reservation {
+	if !pl.reservationsDue() {
+		return nil
+	}
+	pl.lockAll()
+	defer pl.unlockAll()
+	pl.reservationLock.Lock()
+	defer pl.reservationLock.Unlock()
+	return pl.processReservationsLocked()
+}
+
+// reservationsDue is the cheap check done before taking every lock
+func (pl *ParkingLot) reservationsDue() bool {
+	now := pl.clock.Now()
+	pl.reservationLock.Lock()
+	defer pl.reservationLock.Unlock()
+	for _, r := range pl.reservations {
+		if r.Status == ReservationPending && !now.Before(r.From) {
+			return true
+		}
+		if r.Status == ReservationHeld && !now.Before(pl.noShowDeadline(r)) {
+			return true
+		}
+	}
+	return false
+}
+
+func (pl *ParkingLot) noShowDeadline(r *Reservation) time.Time {
+	deadline := r.From.Add(pl.noShowGrace)
+	if deadline.After(r.To) {
+		return r.To
+	}
+	return deadline
+}
+
+// processReservationsLocked expects every pool lock and the reservation lock to be held.
+// A transition whose journal write fails is left for the next run
+func (pl *ParkingLot) processReservationsLocked() []Reservation {
+	now := pl.clock.Now()
+	var noShows []Reservation
+
+	// oldest window first so earlier bookings get the slots
+	due := make([]*Reservation, 0)
+	for _, r := range pl.reservations {
+		if r.Status.open() && !now.Before(r.From) {
+			due = append(due, r)
+		}
+	}
+	slices.SortFunc(due, func(a *Reservation, b *Reservation) int {
+		if c := a.From.Compare(b.From); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Id, b.Id)
+	})
+
+	for _, r := range due {
+		if r.Status == ReservationHeld && !now.Before(pl.noShowDeadline(r)) {
+			fee := noShowFee(pl.pricingStrategy, *r)
+			err := pl.journalEvent(JournalEvent{Kind: EventReservationNoShow, Time: now.UnixNano(), ReservationId: r.Id, Fee: &fee})
+			if err != nil {
+				continue
+			}
+			pl.markNoShow(r, fee, now)
+			noShows = append(noShows, *r)
+			continue
+		}
+		if r.Status != ReservationPending {
+			continue
+		}
+		if !now.Before(r.To) {
+			if err := pl.journalEvent(JournalEvent{Kind: EventReservationExpired, Time: now.UnixNano(), ReservationId: r.Id}); err == nil {
+				r.Status = ReservationExpired
+			}
+			continue
+		}
+		free := pl.chargerCandidates(r.VehicleType, pl.freeSlots(r.VehicleType.SlotType()), 1)
+		if len(free) == 0 {
+			// best effort, walk ins filled the pool : retry on the next run
+			continue
+		}
+		slot := pl.allocationStrategy().Select(r.VehicleType, free)
+		err := pl.journalEvent(JournalEvent{
+			Kind:          EventReservationHeld,
+			Time:          now.UnixNano(),
+			ReservationId: r.Id,
+			FloorId:       slot.FloorId,
+			SlotId:        slot.Id,
+		})
+		if err != nil {
+			continue
+		}
+		pl.holdSlot(r, slot)
+	}
+	return noShows
+}
+
+// holdSlot keeps slot out of the availability index for r
+func (pl *ParkingLot) holdSlot(r *Reservation, slot *Slot) {
+	pl.removeAvailable(slot)
+	slot.HeldBy = r.Id
+	r.Slot = slot
+	r.Status = ReservationHeld
+}
+
+// markNoShow releases the slot held for r, the no-show fee is due from at
+func (pl *ParkingLot) markNoShow(r *Reservation, fee Fee, at time.Time) {
+	pl.releaseHold(r)
+	r.Status = ReservationNoShow
+	r.NoShowFee = fee
+	r.NoShowTime = at
+}
+
+// fulfillReservation is used by the journal replay, the held slot has already been occupied
+func (pl *ParkingLot) fulfillReservation(reservationID string, ticketID string) error {
+	pl.reservationLock.Lock()
+	defer pl.reservationLock.Unlock()
+	reservation, exists := pl.reservations[reservationID]
+	if !exists {
+		return fmt.Errorf("%w: %s", ErrReservationNotFound, reservationID)
+	}
+	reservation.Status = ReservationFulfilled
+	reservation.TicketId = ticketID
+	reservation.Slot = nil
+	return nil
+}
+
+// applyReservationEvent replays a reservation event, every lock but the reservation lock is held
+func (pl *ParkingLot) applyReservationEvent(ev JournalEvent) error {
+	pl.reservationLock.Lock()
+	defer pl.reservationLock.Unlock()
+
+	if ev.Kind == EventReserved {
+		if ev.VehicleType == nil {
+			return fmt.Errorf("reservation %s without vehicle type", ev.ReservationId)
+		}
+		if _, exists := pl.reservations[ev.ReservationId]; exists {
+			return fmt.Errorf("reservation %s already exists", ev.ReservationId)
+		}
+		pl.reservations[ev.ReservationId] = &Reservation{
+			Id:          ev.ReservationId,
+			VehicleType: *ev.VehicleType,
+			From:        time.Unix(0, ev.From),
+			To:          time.Unix(0, ev.To),
+		}
+		// keep reservationSeq ahead of the replayed ids
+		if i := strings.LastIndex(ev.ReservationId, "_R"); i >= 0 {
+			if seq, err := strconv.ParseUint(ev.ReservationId[i+2:], 10, 64); err == nil {
+				pl.reservationSeq = max(pl.reservationSeq, seq)
+			}
+		}
+		return nil
+	}
+
+	r, exists := pl.reservations[ev.ReservationId]
+	if !exists {
+		return fmt.Errorf("%w: %s", ErrReservationNotFound, ev.ReservationId)
+	}
+	if !r.Status.open() {
+		return fmt.Errorf("%w: %s is %s", ErrReservationClosed, r.Id, r.Status.ToString())
+	}
+	switch ev.Kind {
+	case EventReservationHeld:
+		slot, exists := pl.slotStore[slotKey(ev.FloorId, ev.SlotId)]
+		if !exists {
+			return fmt.Errorf("%w: %d-%d", ErrSlotNotFound, ev.FloorId, ev.SlotId)
+		}
+		if r.Status != ReservationPending || slot.IsOccupied || slot.HeldBy != "" {
+			return fmt.Errorf("reservation %s can not hold slot %d-%d", r.Id, slot.FloorId, slot.Id)
+		}
+		pl.holdSlot(r, slot)
+	case EventReservationNoShow:
+		var fee Fee
+		if ev.Fee != nil {
+			fee = *ev.Fee
+		}
+		pl.markNoShow(r, fee, time.Unix(0, ev.Time))
+	case EventReservationExpired:
+		r.Status = ReservationExpired
+	case EventReservationCancelled:
+		pl.releaseHold(r)
+		r.Status = ReservationCancelled
+	}
+	return nil
+}
+
+// releaseHold gives the held slot back to the pool
+func (pl *ParkingLot) releaseHold(r *Reservation) {
+	if r.Slot == nil {
+		return
+	}
+	r.Slot.HeldBy = ""
+	pl.markSlotAvailable(r.Slot)
+	r.Slot = nil
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var reservationDay = time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC)
+
+func at(hour int, minute int) time.Time {
+	return reservationDay.Add(time.Duration(hour)*time.Hour + time.Duration(minute)*time.Minute)
+}
+
+func TestReservationHoldAndCheckIn(t *testing.T) {
+	clock := NewFakeClock(at(8, 0))
+	lot, err := NewParkingLot(Layout{Floors: []FloorLayout{UniformFloor(Car, 2)}}, &NormalPricing{Clock: clock}, WithClock(clock))
+	if err != nil {
+		t.Fatal(err)
+	}
+	reservation, err := lot.Reserve(Car, at(9, 0), at(11, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reservation.Status != ReservationPending || lot.FreeSlotCount(Car) != 2 {
+		t.Fatalf("reservation %s with %d free slots before its window", reservation.Status.ToString(), lot.FreeSlotCount(Car))
+	}
+	vehicle := Vehicle{RegistrationNumber: "KA-01-0001", Type: Car}
+	if _, err := lot.CheckInReservation(reservation.Id, vehicle); !errors.Is(err, ErrReservationNotStarted) {
+		t.Fatalf("early check in err = %v, want ErrReservationNotStarted", err)
+	}
+
+	// the window starts, a slot is held
+	clock.Set(at(9, 0))
+	lot.ProcessReservations()
+	held, _ := lot.GetReservation(reservation.Id)
+	if held.Status != ReservationHeld || held.Slot == nil || lot.FreeSlotCount(Car) != 1 {
+		t.Fatalf("reservation %+v with %d free slots", held, lot.FreeSlotCount(Car))
+	}
+	if _, err := lot.CheckInReservation(reservation.Id, Vehicle{RegistrationNumber: "KA-01-0002", Type: Bike}); err == nil {
+		t.Fatal("a Bike checked in on a Car reservation")
+	}
+
+	ticket, err := lot.CheckInReservation(reservation.Id, vehicle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ticket.SlotDetails != held.Slot {
+		t.Fatalf("parked in %d-%d, held %d-%d", ticket.SlotDetails.FloorId, ticket.SlotDetails.Id, held.Slot.FloorId, held.Slot.Id)
+	}
+	fulfilled, _ := lot.GetReservation(reservation.Id)
+	if fulfilled.Status != ReservationFulfilled || fulfilled.TicketId != ticket.Id {
+		t.Fatalf("reservation %+v after check in", fulfilled)
+	}
+	if _, err := lot.CheckInReservation(reservation.Id, vehicle); !errors.Is(err, ErrReservationClosed) {
+		t.Fatalf("second check in err = %v, want ErrReservationClosed", err)
+	}
+}
+
+func TestReservationCapacityAndCancel(t *testing.T) {
+	clock := NewFakeClock(at(8, 0))
+	lot, err := NewParkingLot(Layout{Floors: []FloorLayout{UniformFloor(Car, 1)}}, &NormalPricing{Clock: clock}, WithClock(clock))
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, err := lot.Reserve(Car, at(9, 0), at(10, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := lot.Reserve(Car, at(9, 30), at(10, 30)); !errors.Is(err, ErrNoCapacity) {
+		t.Fatalf("overlapping reservation err = %v, want ErrNoCapacity", err)
+	}
+	// back to back windows do not overlap
+	if _, err := lot.Reserve(Car, at(10, 0), at(11, 0)); err != nil {
+		t.Fatal(err)
+	}
+	for _, window := range [][2]time.Time{{at(9, 0), at(9, 0)}, {at(6, 0), at(7, 0)}} {
+		if _, err := lot.Reserve(Car, window[0], window[1]); err == nil {
+			t.Fatalf("reservation %v - %v accepted", window[0], window[1])
+		}
+	}
+
+	clock.Set(at(9, 5))
+	lot.ProcessReservations()
+	if lot.FreeSlotCount(Car) != 0 {
+		t.Fatalf("%d free slots while held, want 0", lot.FreeSlotCount(Car))
+	}
+	if err := lot.CancelReservation(first.Id); err != nil {
+		t.Fatal(err)
+	}
+	if lot.FreeSlotCount(Car) != 1 {
+		t.Fatalf("%d free slots after cancel, want 1", lot.FreeSlotCount(Car))
+	}
+	if err := lot.CancelReservation(first.Id); !errors.Is(err, ErrReservationClosed) {
+		t.Fatalf("second cancel err = %v, want ErrReservationClosed", err)
+	}
+	if err := lot.CancelReservation("PL_R99"); !errors.Is(err, ErrReservationNotFound) {
+		t.Fatalf("unknown cancel err = %v, want ErrReservationNotFound", err)
+	}
+	// the freed slot now has room for a new booking in the window
+	if _, err := lot.Reserve(Car, at(9, 30), at(10, 0)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReservationExpiresWithoutSlot(t *testing.T) {
+	clock := NewFakeClock(at(8, 0))
+	lot, err := NewParkingLot(Layout{Floors: []FloorLayout{UniformFloor(Car, 1)}}, &NormalPricing{Clock: clock}, WithClock(clock))
+	if err != nil {
+		t.Fatal(err)
+	}
+	reservation, err := lot.Reserve(Car, at(9, 0), at(10, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a walk in takes the only slot before the window starts
+	if _, err := lot.CheckIn(Vehicle{RegistrationNumber: "KA-01-0001", Type: Car}); err != nil {
+		t.Fatal(err)
+	}
+	clock.Set(at(10, 0))
+	if noShows := lot.ProcessReservations(); len(noShows) != 0 {
+		t.Fatalf("no-shows %+v for a reservation never held", noShows)
+	}
+	expired, _ := lot.GetReservation(reservation.Id)
+	if expired.Status != ReservationExpired || expired.NoShowFee.Total != 0 {
+		t.Fatalf("reservation %+v, want EXPIRED without fee", expired)
+	}
+}
+
+func TestReservationNoShowIsBilled(t *testing.T) {
+	clock := NewFakeClock(at(9, 0))
+	lot, err := NewParkingLot(Layout{Floors: []FloorLayout{UniformFloor(Car, 2)}}, &NormalPricing{Clock: clock}, WithClock(clock))
+	if err != nil {
+		t.Fatal(err)
+	}
+	reservation, err := lot.Reserve(Car, at(10, 0), at(12, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clock.Set(at(10, 0))
+	lot.ProcessReservations()
+	if got, _ := lot.GetReservation(reservation.Id); got.Status != ReservationHeld {
+		t.Fatalf("status at the window start %s, want HELD", got.Status.ToString())
+	}
+	if free := lot.FreeSlotCount(Car); free != 1 {
+		t.Fatalf("%d free Car slots while held, want 1", free)
+	}
+
+	clock.Set(at(10, 0).Add(DefaultNoShowGrace))
+	noShows := lot.ProcessReservations()
+	if len(noShows) != 1 || noShows[0].NoShowFee.Total != 20 {
+		t.Fatalf("no-shows %+v, want one charged 20", noShows)
+	}
+	if free := lot.FreeSlotCount(Car); free != 2 {
+		t.Fatalf("%d free Car slots after the no-show, want 2", free)
+	}
+	report := lot.Revenue(reservationDay, reservationDay.AddDate(0, 0, 1))
+	if report.NoShows != 1 || report.NoShowFees != 20 {
+		t.Fatalf("revenue %+v, want the no-show fee", report)
+	}
+}
+
+func TestReservationIsBestEffort(t *testing.T) {
+	clock := NewFakeClock(at(9, 0))
+	lot, err := NewParkingLot(Layout{Floors: []FloorLayout{UniformFloor(Car, 1)}}, &NormalPricing{Clock: clock}, WithClock(clock))
+	if err != nil {
+		t.Fatal(err)
+	}
+	reservation, err := lot.Reserve(Car, at(10, 0), at(11, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a walk in takes the only slot before the window starts
+	if _, err := lot.CheckIn(Vehicle{RegistrationNumber: "KA-01-0001", Type: Car}); err != nil {
+		t.Fatal(err)
+	}
+
+	clock.Set(at(10, 0))
+	lot.ProcessReservations()
+	if got, _ := lot.GetReservation(reservation.Id); got.Status != ReservationPending {
+		t.Fatalf("status on a full pool %s, want PENDING", got.Status.ToString())
+	}
+	clock.Set(at(11, 0))
+	lot.ProcessReservations()
+	if got, _ := lot.GetReservation(reservation.Id); got.Status != ReservationExpired {
+		t.Fatalf("status after the window %s, want EXPIRED", got.Status.ToString())
+	}
+	if report := lot.Revenue(reservationDay, reservationDay.AddDate(0, 0, 1)); report.NoShows != 0 {
+		t.Fatalf("revenue %+v, an expired reservation is not a no-show", report)
+	}
+}
+
+func TestReservationReplay(t *testing.T) {
+	clock := NewFakeClock(at(9, 0))
+	path := filepath.Join(t.TempDir(), "journal.log")
+	lot := newJournaledLot(t, clock, path)
+
+	fulfilled, err := lot.Reserve(Car, at(10, 0), at(12, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	noShow, err := lot.Reserve(Car, at(10, 0), at(12, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cancelled, err := lot.Reserve(Car, at(13, 0), at(14, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := lot.CancelReservation(cancelled.Id); err != nil {
+		t.Fatal(err)
+	}
+	clock.Set(at(10, 0))
+	ticket, err := lot.CheckInReservation(fulfilled.Id, Vehicle{RegistrationNumber: "KA-01-0001", Type: Car})
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock.Set(at(11, 0))
+	lot.ProcessReservations()
+	pending, err := lot.Reserve(Car, at(15, 0), at(16, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	replayed := newJournaledLot(t, clock, path)
+	for _, want := range []Reservation{fulfilled, noShow, cancelled, pending} {
+		live, _ := lot.GetReservation(want.Id)
+		got, err := replayed.GetReservation(want.Id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Status != live.Status || got.TicketId != live.TicketId || got.NoShowFee.Total != live.NoShowFee.Total ||
+			!got.NoShowTime.Equal(live.NoShowTime) {
+			t.Fatalf("replayed %+v, live %+v", got, live)
+		}
+	}
+	if got, _ := replayed.GetReservation(fulfilled.Id); got.TicketId != ticket.Id {
+		t.Fatalf("replayed reservation fulfilled by %q, want %s", got.TicketId, ticket.Id)
+	}
+	if free := replayed.FreeSlotCount(Car); free != 1 {
+		t.Fatalf("%d free Car slots after replay, want 1", free)
+	}
+	if report := replayed.Revenue(reservationDay, reservationDay.AddDate(0, 0, 1)); report.NoShowFees != 20 {
+		t.Fatalf("replayed revenue %+v, want the no-show fee", report)
+	}
+	// the next booking is numbered after the replayed ones
+	next, err := replayed.Reserve(Car, at(17, 0), at(18, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next.Id == pending.Id {
+		t.Fatalf("replayed lot reissued %s", next.Id)
+	}
+}
@@ -0,0 +1,667 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// Server exposes a ParkingLot as a JSON REST API
+//
+//	POST /park                  {"registrationNumber": "KA-01-1234", "type": "Car"}
+//	POST /tickets/{id}/unpark       (402 while a fee is due, pay first)
+//	POST /tickets/{id}/pay          {"method": "card", "account": "4111"}   (or cash with "tendered", wallet; the vehicle is still inside)
+//	POST /tickets/{id}/checkout     {"method": "card", "account": "4111"}   (pay & leave)
+//	GET  /tickets/{id}/receipt
+//	POST /tickets/{id}/refunds      {"amount": 20, "reason": "barrier fault"}
+//	POST /tickets/{id}/adjustments  {"amount": -10, "reason": "goodwill"}
+//	POST /tickets/{id}/charge   {"kWh": 12.5}   (reported by the charger of the slot)
+//	POST /tickets/{id}/coupon   {"code": "SHOP10"}   (400 unless the pricing checks coupons, see DiscountPricing)
+//	GET  /tickets/{id}
+//	GET  /vehicles/{registration}   (where the vehicle is parked)
+//	POST   /waitlist                {"registrationNumber": "KA-01-1234", "type": "Car"}
+//	GET    /waitlist/{id}           (position, or the slot held until expiresAt)
+//	POST   /waitlist/{id}/checkin
+//	DELETE /waitlist/{id}
+//	GET  /slots/free?type=Car
+//	GET  /slots/occupied?type=Car
+//	POST   /slots/{floor}/{slot}/disable?drain=true
+//	POST   /slots/{floor}/{slot}/enable
+//	POST   /floors                  {"slots": ["Car", "Car", {"type": "Car", "charger": true}]}
+//	DELETE /floors/{floor}?drain=true
+//	GET  /reports/revenue?from=2026-01-01&to=2026-02-01     (dates or RFC 3339 times, to is excluded)
+//	GET  /reports/occupancy?from=2026-01-01&to=2026-01-02
+//	GET  /reports/history.csv?from=2026-01-01&to=2026-02-01
+//	GET  /events                    (server-sent events, one JSON event per message)
+type Server struct {
+	lot *ParkingLot
+	mux *http.ServeMux
+}
+
+func NewServer(lot *ParkingLot) *Server {
+	s := &Server{lot: lot, mux: http.NewServeMux()}
+	s.mux.HandleFunc("POST /park", s.park)
+	s.mux.HandleFunc("POST /tickets/{id}/unpark", s.unpark)
+	s.mux.HandleFunc("POST /tickets/{id}/pay", s.pay)
+	s.mux.HandleFunc("POST /tickets/{id}/checkout", s.checkout)
+	s.mux.HandleFunc("GET /tickets/{id}/receipt", s.receipt)
+	s.mux.HandleFunc("POST /tickets/{id}/refunds", s.ledgerEntry(TxRefund))
+	s.mux.HandleFunc("POST /tickets/{id}/adjustments", s.ledgerEntry(TxAdjustment))
+	s.mux.HandleFunc("POST /tickets/{id}/charge", s.charge)
+	s.mux.HandleFunc("POST /tickets/{id}/coupon", s.coupon)
+	s.mux.HandleFunc("GET /tickets/{id}", s.ticket)
+	s.mux.HandleFunc("GET /vehicles/{registration}", s.vehicle)
+	s.mux.HandleFunc("POST /waitlist", s.joinWaitlist)
+	s.mux.HandleFunc("GET /waitlist/{id}", s.waitlistStatus)
+	s.mux.HandleFunc("POST /waitlist/{id}/checkin", s.checkInWaitlist)
+	s.mux.HandleFunc("DELETE /waitlist/{id}", s.leaveWaitlist)
+	s.mux.HandleFunc("GET /slots/free", s.slots(false))
+	s.mux.HandleFunc("GET /slots/occupied", s.slots(true))
+	s.mux.HandleFunc("POST /slots/{floor}/{slot}/disable", s.setSlotEnabled(false))
+	s.mux.HandleFunc("POST /slots/{floor}/{slot}/enable", s.setSlotEnabled(true))
+	s.mux.HandleFunc("POST /floors", s.addFloor)
+	s.mux.HandleFunc("DELETE /floors/{floor}", s.removeFloor)
+	s.mux.HandleFunc("GET /reports/revenue", s.revenue)
+	s.mux.HandleFunc("GET /reports/occupancy", s.occupancy)
+	s.mux.HandleFunc("GET /reports/history.csv", s.historyCSV)
+	s.mux.HandleFunc("GET /events", s.events)
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+type parkRequest struct {
+	RegistrationNumber string `json:"registrationNumber"`
+	// Type is a pointer so a missing type is rejected instead of parking as Bike, the zero VehicleType
+	Type *VehicleType `json:"type"`
+}
+
+func (req parkRequest) vehicle() (Vehicle, error) {
+	if req.RegistrationNumber == "" {
+		return Vehicle{}, errors.New("registrationNumber is required")
+	}
+	if req.Type == nil {
+		return Vehicle{}, errors.New("type is required")
+	}
+	return Vehicle{RegistrationNumber: req.RegistrationNumber, Type: *req.Type}, nil
+}
+
+type chargeRequest struct {
+	KWh float64 `json:"kWh"`
+}
+
+type couponRequest struct {
+	Code string `json:"code"`
+}
+
+type ledgerRequest struct {
+	Amount int    `json:"amount"`
+	Reason string `json:"reason"`
+}
+
+type ticketResponse struct {
+	Id                 string      `json:"id"`
+	Visit              uint64      `json:"visit"`
+	RegistrationNumber string      `json:"registrationNumber"`
+	VehicleType        VehicleType `json:"vehicleType"`
+	Floor              int         `json:"floor"`
+	Slot               int         `json:"slot"`
+	EntryTime          time.Time   `json:"entryTime"`
+	Status             string      `json:"status"`
+	EnergyKWh          float64     `json:"energyKWh,omitempty"`
+	Coupon             string      `json:"coupon,omitempty"`
+	PaidTime           *time.Time  `json:"paidTime,omitempty"`
+	ExitTime           *time.Time  `json:"exitTime,omitempty"`
+	Fee                *Fee        `json:"fee,omitempty"`
+}
+
+type receiptResponse struct {
+	TicketId           string        `json:"ticketId"`
+	RegistrationNumber string        `json:"registrationNumber"`
+	VehicleType        VehicleType   `json:"vehicleType"`
+	Floor              int           `json:"floor"`
+	Slot               int           `json:"slot"`
+	EntryTime          time.Time     `json:"entryTime"`
+	PaidTime           *time.Time    `json:"paidTime,omitempty"`
+	ExitTime           *time.Time    `json:"exitTime,omitempty"`
+	EnergyKWh          float64       `json:"energyKWh,omitempty"`
+	Fee                Fee           `json:"fee"`
+	Discounts          []FeeLine     `json:"discounts,omitempty"`
+	Payments           []Transaction `json:"payments,omitempty"`
+	Balance            int           `json:"balance"`
+}
+
+type slotsResponse struct {
+	Type   VehicleType   `json:"type"`
+	Count  int           `json:"count"`
+	Floors map[int][]int `json:"floors"`
+}
+
+type revenueResponse struct {
+	From          time.Time      `json:"from"`
+	To            time.Time      `json:"to"`
+	Tickets       int            `json:"tickets"`
+	Total         int            `json:"total"`
+	Refunds       int            `json:"refunds,omitempty"`
+	Adjustments   int            `json:"adjustments,omitempty"`
+	ByDay         map[string]int `json:"byDay"`
+	ByVehicleType map[string]int `json:"byVehicleType"`
+	ByFloor       map[int]int    `json:"byFloor"`
+	AverageStay   string         `json:"averageStay"`
+	NoShows       int            `json:"noShows,omitempty"`
+	NoShowFees    int            `json:"noShowFees,omitempty"`
+}
+
+type occupancyResponse struct {
+	Hour time.Time `json:"hour"`
+	Peak int       `json:"peak"`
+}
+
+type vehicleResponse struct {
+	RegistrationNumber string    `json:"registrationNumber"`
+	TicketId           string    `json:"ticketId"`
+	Floor              int       `json:"floor"`
+	Slot               int       `json:"slot"`
+	Slots              []int     `json:"slots"`
+	EntryTime          time.Time `json:"entryTime"`
+}
+
+type waitlistResponse struct {
+	Id        string     `json:"id"`
+	Position  int        `json:"position,omitempty"`
+	Floor     *int       `json:"floor,omitempty"`
+	Slot      *int       `json:"slot,omitempty"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+}
+
+type floorResponse struct {
+	Floor    int  `json:"floor"`
+	Draining bool `json:"draining,omitempty"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+func (s *Server) park(w http.ResponseWriter, r *http.Request) {
+	var req parkRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	vehicle, err := req.vehicle()
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	ticket, err := s.lot.CheckIn(vehicle)
+	if err != nil {
+		writeError(w, statusFor(err), err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, toTicketResponse(ticket))
+}
+
+func (s *Server) unpark(w http.ResponseWriter, r *http.Request) {
+	receipt, err := s.lot.Unpark(r.PathValue("id"))
+	if err != nil {
+		writeError(w, statusFor(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, toReceiptResponse(receipt))
+}
+
+func (s *Server) pay(w http.ResponseWriter, r *http.Request) {
+	var payment Payment
+	if err := json.NewDecoder(r.Body).Decode(&payment); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if _, err := s.lot.Pay(r.PathValue("id"), payment); err != nil {
+		writeError(w, statusFor(err), err)
+		return
+	}
+	s.ticket(w, r)
+}
+
+func (s *Server) checkout(w http.ResponseWriter, r *http.Request) {
+	var payment Payment
+	if err := json.NewDecoder(r.Body).Decode(&payment); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	ticket, err := s.lot.GetTicket(r.PathValue("id"))
+	if err != nil {
+		writeError(w, statusFor(err), err)
+		return
+	}
+	receipt, err := s.lot.Checkout(ticket, payment)
+	if err != nil {
+		writeError(w, statusFor(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, toReceiptResponse(receipt))
+}
+
+func (s *Server) receipt(w http.ResponseWriter, r *http.Request) {
+	ticket, err := s.lot.GetTicket(r.PathValue("id"))
+	if err != nil {
+		writeError(w, statusFor(err), err)
+		return
+	}
+	if ticket.Status == TicketActive {
+		writeError(w, http.StatusConflict, errors.New("ticket neither paid nor checked out"))
+		return
+	}
+	writeJSON(w, http.StatusOK, toReceiptResponse(ticket.receipt()))
+}
+
+// ledgerEntry records a refund or an adjustment and returns the updated receipt
+func (s *Server) ledgerEntry(kind TransactionKind) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req ledgerRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		var err error
+		if kind == TxRefund {
+			_, err = s.lot.Refund(r.PathValue("id"), req.Amount, req.Reason)
+		} else {
+			_, err = s.lot.Adjust(r.PathValue("id"), req.Amount, req.Reason)
+		}
+		if err != nil {
+			writeError(w, statusFor(err), err)
+			return
+		}
+		s.receipt(w, r)
+	}
+}
+
+func (s *Server) charge(w http.ResponseWriter, r *http.Request) {
+	var req chargeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := s.lot.ReportCharge(r.PathValue("id"), req.KWh); err != nil {
+		writeError(w, statusFor(err), err)
+		return
+	}
+	ticket, err := s.lot.GetTicket(r.PathValue("id"))
+	if err != nil {
+		writeError(w, statusFor(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, toTicketResponse(ticket))
+}
+
+func (s *Server) coupon(w http.ResponseWriter, r *http.Request) {
+	var req couponRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := s.lot.ApplyCoupon(r.PathValue("id"), req.Code); err != nil {
+		writeError(w, statusFor(err), err)
+		return
+	}
+	ticket, err := s.lot.GetTicket(r.PathValue("id"))
+	if err != nil {
+		writeError(w, statusFor(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, toTicketResponse(ticket))
+}
+
+func (s *Server) ticket(w http.ResponseWriter, r *http.Request) {
+	ticket, err := s.lot.GetTicket(r.PathValue("id"))
+	if err != nil {
+		writeError(w, statusFor(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, toTicketResponse(ticket))
+}
+
+func (s *Server) vehicle(w http.ResponseWriter, r *http.Request) {
+	registration := r.PathValue("registration")
+	location, err := s.lot.FindVehicle(registration)
+	if err != nil {
+		writeError(w, statusFor(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, vehicleResponse{
+		RegistrationNumber: registration,
+		TicketId:           location.TicketId,
+		Floor:              location.FloorId,
+		Slot:               location.SlotId,
+		Slots:              location.SlotIds,
+		EntryTime:          location.CheckinTime.UTC(),
+	})
+}
+
+func (s *Server) joinWaitlist(w http.ResponseWriter, r *http.Request) {
+	var req parkRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	vehicle, err := req.vehicle()
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	entry, err := s.lot.JoinWaitlist(vehicle)
+	if err != nil {
+		writeError(w, statusFor(err), err)
+		return
+	}
+	s.writeWaitlistStatus(w, http.StatusCreated, entry.Id)
+}
+
+func (s *Server) waitlistStatus(w http.ResponseWriter, r *http.Request) {
+	s.writeWaitlistStatus(w, http.StatusOK, r.PathValue("id"))
+}
+
+func (s *Server) writeWaitlistStatus(w http.ResponseWriter, code int, waitID string) {
+	status, err := s.lot.WaitlistStatus(waitID)
+	if err != nil {
+		writeError(w, statusFor(err), err)
+		return
+	}
+	resp := waitlistResponse{Id: waitID, Position: status.Position}
+	if hold := status.Hold; hold != nil {
+		expires := hold.ExpiresAt.UTC()
+		resp.Floor, resp.Slot, resp.ExpiresAt = &hold.FloorId, &hold.SlotId, &expires
+	}
+	writeJSON(w, code, resp)
+}
+
+func (s *Server) checkInWaitlist(w http.ResponseWriter, r *http.Request) {
+	ticket, err := s.lot.CheckInWaitlist(r.PathValue("id"))
+	if err != nil {
+		writeError(w, statusFor(err), err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, toTicketResponse(ticket))
+}
+
+func (s *Server) leaveWaitlist(w http.ResponseWriter, r *http.Request) {
+	if err := s.lot.LeaveWaitlist(r.PathValue("id")); err != nil {
+		writeError(w, statusFor(err), err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) slots(occupied bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vtype, err := ParseVehicleType(r.URL.Query().Get("type"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		floors := s.lot.FreeSlots(vtype)
+		if occupied {
+			floors = s.lot.OccupiedSlots(vtype)
+		}
+		count := 0
+		for _, ids := range floors {
+			count += len(ids)
+		}
+		writeJSON(w, http.StatusOK, slotsResponse{Type: vtype, Count: count, Floors: floors})
+	}
+}
+
+func (s *Server) setSlotEnabled(enabled bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		floor, errFloor := strconv.Atoi(r.PathValue("floor"))
+		slot, errSlot := strconv.Atoi(r.PathValue("slot"))
+		if errFloor != nil || errSlot != nil {
+			writeError(w, http.StatusBadRequest, errors.New("floor and slot have to be numbers"))
+			return
+		}
+		var err error
+		if enabled {
+			err = s.lot.EnableSlot(floor, slot)
+		} else {
+			err = s.lot.DisableSlot(floor, slot, drainMode(r))
+		}
+		if err != nil {
+			writeError(w, statusFor(err), err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (s *Server) addFloor(w http.ResponseWriter, r *http.Request) {
+	var floor FloorLayout
+	if err := json.NewDecoder(r.Body).Decode(&floor); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	id, err := s.lot.AddFloor(floor)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, floorResponse{Floor: id})
+}
+
+func (s *Server) removeFloor(w http.ResponseWriter, r *http.Request) {
+	floor, err := strconv.Atoi(r.PathValue("floor"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, errors.New("floor has to be a number"))
+		return
+	}
+	if err := s.lot.RemoveFloor(floor, drainMode(r)); err != nil {
+		writeError(w, statusFor(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, floorResponse{Floor: floor, Draining: slices.Contains(s.lot.DrainingFloors(), floor)})
+}
+
+func drainMode(r *http.Request) RemovalMode {
+	if r.URL.Query().Get("drain") == "true" {
+		return Drain
+	}
+	return RefuseOccupied
+}
+
+func (s *Server) revenue(w http.ResponseWriter, r *http.Request) {
+	from, to, err := reportWindow(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	report := s.lot.Revenue(from, to)
+	byVehicleType := make(map[string]int, len(report.ByVehicleType))
+	for vtype, total := range report.ByVehicleType {
+		byVehicleType[vtype.ToString()] = total
+	}
+	writeJSON(w, http.StatusOK, revenueResponse{
+		From:          report.From,
+		To:            report.To,
+		Tickets:       report.Tickets,
+		Total:         report.Total,
+		Refunds:       report.Refunds,
+		Adjustments:   report.Adjustments,
+		ByDay:         report.ByDay,
+		ByVehicleType: byVehicleType,
+		ByFloor:       report.ByFloor,
+		AverageStay:   report.AverageStay.String(),
+		NoShows:       report.NoShows,
+		NoShowFees:    report.NoShowFees,
+	})
+}
+
+func (s *Server) occupancy(w http.ResponseWriter, r *http.Request) {
+	from, to, err := reportWindow(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	hours := make([]occupancyResponse, 0)
+	for _, hour := range s.lot.PeakOccupancy(from, to) {
+		hours = append(hours, occupancyResponse{Hour: hour.Hour, Peak: hour.Peak})
+	}
+	writeJSON(w, http.StatusOK, hours)
+}
+
+func (s *Server) historyCSV(w http.ResponseWriter, r *http.Request) {
+	from, to, err := reportWindow(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	// the export is buffered so a failure still gets an error response
+	var out bytes.Buffer
+	if err := s.lot.ExportCSV(&out, from, to); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.Header().Set("Content-Type", "text/csv")
+	w.Write(out.Bytes())
+}
+
+// events streams the lot events until the client goes away, a slow client loses the oldest ones
+func (s *Server) events(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, errors.New("streaming not supported"))
+		return
+	}
+	sub := s.lot.Subscribe(64, DropOldest)
+	defer s.lot.Unsubscribe(sub)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case ev := <-sub.C:
+			data, err := json.Marshal(ev)
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", ev.Kind.ToString(), data)
+			flusher.Flush()
+		}
+	}
+}
+
+// reportWindow reads the from & to query parameters, both are required
+func reportWindow(r *http.Request) (time.Time, time.Time, error) {
+	from, err := parseReportTime(r.URL.Query().Get("from"))
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	to, err := parseReportTime(r.URL.Query().Get("to"))
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	if !from.Before(to) {
+		return time.Time{}, time.Time{}, errors.New("from has to be before to")
+	}
+	return from, to, nil
+}
+
+func parseReportTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.DateOnly, value); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+func toReceiptResponse(receipt Receipt) receiptResponse {
+	resp := receiptResponse{
+		TicketId:           receipt.TicketId,
+		RegistrationNumber: receipt.RegistrationNumber,
+		VehicleType:        receipt.VehicleType,
+		Floor:              receipt.FloorId,
+		Slot:               receipt.SlotId,
+		EntryTime:          time.Unix(0, receipt.CheckinTime).UTC(),
+		EnergyKWh:          receipt.EnergyKWh,
+		Fee:                receipt.Fee,
+		Discounts:          receipt.Discounts,
+		Payments:           receipt.Payments,
+		Balance:            receipt.Balance,
+	}
+	if receipt.PaidTime != 0 {
+		paid := time.Unix(0, receipt.PaidTime).UTC()
+		resp.PaidTime = &paid
+	}
+	if receipt.CheckoutTime != 0 {
+		exit := time.Unix(0, receipt.CheckoutTime).UTC()
+		resp.ExitTime = &exit
+	}
+	return resp
+}
+
+func toTicketResponse(ticket ParkingTicket) ticketResponse {
+	resp := ticketResponse{
+		Id:                 ticket.Id,
+		Visit:              ticket.Visit,
+		RegistrationNumber: ticket.VehicleParked.RegistrationNumber,
+		VehicleType:        ticket.VehicleParked.Type,
+		Floor:              ticket.SlotDetails.FloorId,
+		Slot:               ticket.SlotDetails.Id,
+		EntryTime:          time.Unix(0, ticket.CheckinTime).UTC(),
+		Status:             ticket.Status.ToString(),
+		EnergyKWh:          ticket.EnergyKWh,
+		Coupon:             ticket.Coupon,
+	}
+	if ticket.Status != TicketActive {
+		resp.Fee = &ticket.Fee
+	}
+	if ticket.PaidTime != 0 {
+		paid := time.Unix(0, ticket.PaidTime).UTC()
+		resp.PaidTime = &paid
+	}
+	if ticket.Status == TicketClosed {
+		exit := time.Unix(0, ticket.CheckoutTime).UTC()
+		resp.ExitTime = &exit
+	}
+	return resp
+}
+
+// statusFor maps the lot errors to http status codes
+func statusFor(err error) int {
+	switch {
+	case errors.Is(err, ErrNoSlotAvailable), errors.Is(err, ErrTicketClosed), errors.Is(err, ErrTicketPaid), errors.Is(err, ErrVehicleAlreadyParked), errors.Is(err, ErrSlotOccupied),
+		errors.Is(err, ErrAlreadyWaiting), errors.Is(err, ErrNoHold), errors.Is(err, ErrTicketNotPaid),
+		errors.Is(err, ErrLastFloor), errors.Is(err, ErrFloorDraining):
+		return http.StatusConflict
+	case errors.Is(err, ErrTicketNotFound), errors.Is(err, ErrVehicleNotFound), errors.Is(err, ErrSlotNotFound), errors.Is(err, ErrFloorNotFound),
+		errors.Is(err, ErrWaitlistNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrTicketForged), errors.Is(err, ErrNoCharger), errors.Is(err, ErrInvalidCoupon), errors.Is(err, ErrInvalidRefund),
+		errors.Is(err, ErrInvalidAdjustment), errors.Is(err, ErrCanNotWait):
+		return http.StatusBadRequest
+	case errors.Is(err, ErrPaymentDeclined), errors.Is(err, ErrPaymentRequired):
+		return http.StatusPaymentRequired
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, errorResponse{Error: err.Error()})
+}
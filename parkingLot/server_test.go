@@ -0,0 +1,252 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func serve(t *testing.T, srv *Server, method string, path string, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(method, path, strings.NewReader(body)))
+	return rec
+}
+
+func expectStatus(t *testing.T, rec *httptest.ResponseRecorder, want int) {
+	t.Helper()
+	if rec.Code != want {
+		t.Fatalf("status %d, want %d: %s", rec.Code, want, rec.Body.String())
+	}
+}
+
+func TestServerParkUnparkRoundTrip(t *testing.T) {
+	clock := NewFakeClock(time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC))
+	lot, err := NewParkingLot(Layout{Floors: []FloorLayout{UniformFloor(Car, 2)}}, &NormalPricing{Clock: clock}, WithClock(clock))
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := NewServer(lot)
+
+	rec := serve(t, srv, http.MethodPost, "/park", `{"registrationNumber": "KA-01-0001", "type": "Car"}`)
+	expectStatus(t, rec, http.StatusCreated)
+	var ticket ticketResponse
+	if err := json.NewDecoder(rec.Body).Decode(&ticket); err != nil {
+		t.Fatal(err)
+	}
+	if ticket.RegistrationNumber != "KA-01-0001" || ticket.Status != "ACTIVE" || ticket.Fee != nil {
+		t.Fatalf("ticket %+v", ticket)
+	}
+
+	rec = serve(t, srv, http.MethodGet, "/slots/occupied?type=Car", "")
+	expectStatus(t, rec, http.StatusOK)
+	var occupied slotsResponse
+	if err := json.NewDecoder(rec.Body).Decode(&occupied); err != nil {
+		t.Fatal(err)
+	}
+	if occupied.Count != 1 || len(occupied.Floors[ticket.Floor]) != 1 || occupied.Floors[ticket.Floor][0] != ticket.Slot {
+		t.Fatalf("occupied slots %+v", occupied)
+	}
+
+	clock.Advance(90 * time.Minute)
+	rec = serve(t, srv, http.MethodPost, "/tickets/"+ticket.Id+"/unpark", "")
+	expectStatus(t, rec, http.StatusOK)
+	var receipt receiptResponse
+	if err := json.NewDecoder(rec.Body).Decode(&receipt); err != nil {
+		t.Fatal(err)
+	}
+	if receipt.TicketId != ticket.Id || receipt.Fee.Total != 2*Car.BasePrice() || receipt.ExitTime.Sub(receipt.EntryTime) != 90*time.Minute {
+		t.Fatalf("receipt %+v", receipt)
+	}
+	expectStatus(t, serve(t, srv, http.MethodPost, "/tickets/"+ticket.Id+"/unpark", ""), http.StatusConflict)
+
+	rec = serve(t, srv, http.MethodGet, "/slots/free?type=Car", "")
+	expectStatus(t, rec, http.StatusOK)
+	var free slotsResponse
+	if err := json.NewDecoder(rec.Body).Decode(&free); err != nil {
+		t.Fatal(err)
+	}
+	if free.Count != 2 {
+		t.Fatalf("free slots %+v, want 2", free)
+	}
+}
+
+func TestServerErrors(t *testing.T) {
+	lot, err := NewParkingLot(Layout{Floors: []FloorLayout{UniformFloor(Car, 1)}}, &NormalPricing{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := NewServer(lot)
+	expectStatus(t, serve(t, srv, http.MethodGet, "/tickets/unknown", ""), http.StatusNotFound)
+	expectStatus(t, serve(t, srv, http.MethodPost, "/tickets/unknown/unpark", ""), http.StatusNotFound)
+	expectStatus(t, serve(t, srv, http.MethodGet, "/slots/free?type=Boat", ""), http.StatusBadRequest)
+	expectStatus(t, serve(t, srv, http.MethodPost, "/park", `{"type": "Car"}`), http.StatusBadRequest)
+	expectStatus(t, serve(t, srv, http.MethodPost, "/park", `{"registrationNumber": "KA-01-0001", "type": "Car"}`), http.StatusCreated)
+	// the only slot is taken
+	expectStatus(t, serve(t, srv, http.MethodPost, "/park", `{"registrationNumber": "KA-01-0002", "type": "Car"}`), http.StatusConflict)
+}
+
+func newTestServer(t *testing.T) (*Server, *FakeClock, *FakeGateway) {
+	t.Helper()
+	clock := NewFakeClock(time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC))
+	gateway := NewFakeGateway()
+	lot, err := NewParkingLot(Layout{Floors: []FloorLayout{UniformFloor(Car, 1)}},
+		&NormalPricing{Clock: clock}, WithID("T1"), WithClock(clock), WithPaymentProcessor(gateway))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewServer(lot), clock, gateway
+}
+
+func parkTestCar(t *testing.T, srv *Server, registration string) ticketResponse {
+	t.Helper()
+	rec := serve(t, srv, http.MethodPost, "/park", `{"registrationNumber": "`+registration+`", "type": "Car"}`)
+	expectStatus(t, rec, http.StatusCreated)
+	var ticket ticketResponse
+	if err := json.NewDecoder(rec.Body).Decode(&ticket); err != nil {
+		t.Fatal(err)
+	}
+	return ticket
+}
+
+func TestServerParkAndLookup(t *testing.T) {
+	srv, _, _ := newTestServer(t)
+	ticket := parkTestCar(t, srv, "KA-01-0001")
+	if ticket.Id != "T1_0_0_1" || ticket.Floor != 0 || ticket.Slot != 0 || ticket.VehicleType != Car || ticket.Status != "ACTIVE" {
+		t.Fatalf("ticket %+v", ticket)
+	}
+	expectStatus(t, serve(t, srv, http.MethodGet, "/tickets/"+ticket.Id, ""), http.StatusOK)
+	expectStatus(t, serve(t, srv, http.MethodGet, "/tickets/T1_0_9", ""), http.StatusNotFound)
+	expectStatus(t, serve(t, srv, http.MethodPost, "/tickets/T1_0_9/unpark", ""), http.StatusNotFound)
+}
+
+func TestServerParkConflicts(t *testing.T) {
+	srv, _, _ := newTestServer(t)
+	parkTestCar(t, srv, "KA-01-0001")
+	// the vehicle is already inside
+	expectStatus(t, serve(t, srv, http.MethodPost, "/park", `{"registrationNumber": "KA-01-0001", "type": "Car"}`), http.StatusConflict)
+	// the only slot is taken
+	expectStatus(t, serve(t, srv, http.MethodPost, "/park", `{"registrationNumber": "KA-01-0002", "type": "Car"}`), http.StatusConflict)
+}
+
+func TestServerParkValidation(t *testing.T) {
+	srv, _, _ := newTestServer(t)
+	for _, body := range []string{
+		`{"registrationNumber": "KA-01-0001"}`,
+		`{"type": "Car"}`,
+		`{"registrationNumber": "KA-01-0001", "type": "Boat"}`,
+		`not json`,
+	} {
+		expectStatus(t, serve(t, srv, http.MethodPost, "/park", body), http.StatusBadRequest)
+	}
+	if free := srv.lot.FreeSlotCount(Car); free != 1 {
+		t.Fatalf("%d free slots after rejected requests, want 1", free)
+	}
+}
+
+func TestServerCheckoutDeclined(t *testing.T) {
+	srv, clock, gateway := newTestServer(t)
+	ticket := parkTestCar(t, srv, "KA-01-0001")
+	clock.Advance(2 * time.Hour)
+
+	gateway.DeclineCard("4000")
+	rec := serve(t, srv, http.MethodPost, "/tickets/"+ticket.Id+"/checkout", `{"method": "card", "account": "4000"}`)
+	expectStatus(t, rec, http.StatusPaymentRequired)
+	// the vehicle is still inside, a valid card settles the fee
+	rec = serve(t, srv, http.MethodPost, "/tickets/"+ticket.Id+"/checkout", `{"method": "card", "account": "4111"}`)
+	expectStatus(t, rec, http.StatusOK)
+	var receipt receiptResponse
+	if err := json.NewDecoder(rec.Body).Decode(&receipt); err != nil {
+		t.Fatal(err)
+	}
+	if receipt.Fee.Total != 40 || receipt.Balance != 0 || receipt.ExitTime == nil {
+		t.Fatalf("receipt %+v", receipt)
+	}
+	expectStatus(t, serve(t, srv, http.MethodPost, "/tickets/"+ticket.Id+"/unpark", ""), http.StatusConflict)
+}
+
+// unpark does not let an unpaid vehicle out, it pays first
+func TestServerUnparkNeedsPayment(t *testing.T) {
+	srv, clock, _ := newTestServer(t)
+	ticket := parkTestCar(t, srv, "KA-01-0001")
+	clock.Advance(2 * time.Hour)
+
+	expectStatus(t, serve(t, srv, http.MethodPost, "/tickets/"+ticket.Id+"/unpark", ""), http.StatusPaymentRequired)
+	// the slot was not freed
+	rec := serve(t, srv, http.MethodPost, "/park", `{"registrationNumber": "KA-01-0002", "type": "Car"}`)
+	expectStatus(t, rec, http.StatusConflict)
+
+	rec = serve(t, srv, http.MethodPost, "/tickets/"+ticket.Id+"/pay", `{"method": "card", "account": "4111"}`)
+	expectStatus(t, rec, http.StatusOK)
+	rec = serve(t, srv, http.MethodPost, "/tickets/"+ticket.Id+"/unpark", "")
+	expectStatus(t, rec, http.StatusOK)
+	var receipt receiptResponse
+	if err := json.NewDecoder(rec.Body).Decode(&receipt); err != nil {
+		t.Fatal(err)
+	}
+	if receipt.Fee.Total != 40 || receipt.Balance != 0 || receipt.ExitTime == nil {
+		t.Fatalf("receipt %+v", receipt)
+	}
+}
+
+func TestServerRejectsUncheckedCoupon(t *testing.T) {
+	srv, _, _ := newTestServer(t)
+	ticket := parkTestCar(t, srv, "KA-01-0001")
+	rec := serve(t, srv, http.MethodPost, "/tickets/"+ticket.Id+"/coupon", `{"code": "BOGUS"}`)
+	expectStatus(t, rec, http.StatusBadRequest)
+}
+
+func TestServerLedgerErrors(t *testing.T) {
+	srv, clock, _ := newTestServer(t)
+	ticket := parkTestCar(t, srv, "KA-01-0001")
+	adjustments := "/tickets/" + ticket.Id + "/adjustments"
+	// nothing to adjust before the fee is paid
+	expectStatus(t, serve(t, srv, http.MethodPost, adjustments, `{"amount": -10, "reason": "goodwill"}`), http.StatusConflict)
+	expectStatus(t, serve(t, srv, http.MethodPost, "/tickets/"+ticket.Id+"/refunds", `{"amount": 10, "reason": "goodwill"}`), http.StatusBadRequest)
+
+	clock.Advance(time.Hour)
+	expectStatus(t, serve(t, srv, http.MethodPost, "/tickets/"+ticket.Id+"/checkout", `{"method": "card", "account": "4111"}`), http.StatusOK)
+	expectStatus(t, serve(t, srv, http.MethodPost, adjustments, `{"amount": 0, "reason": "goodwill"}`), http.StatusBadRequest)
+	expectStatus(t, serve(t, srv, http.MethodPost, adjustments, `{"amount": -10}`), http.StatusBadRequest)
+	expectStatus(t, serve(t, srv, http.MethodPost, adjustments, `{"amount": -10, "reason": "goodwill"}`), http.StatusOK)
+}
+
+func TestServerFloorConflicts(t *testing.T) {
+	srv, _, _ := newTestServer(t)
+	expectStatus(t, serve(t, srv, http.MethodDelete, "/floors/0", ""), http.StatusConflict)
+
+	rec := serve(t, srv, http.MethodPost, "/floors", `{"slots": ["Car"]}`)
+	expectStatus(t, rec, http.StatusCreated)
+	parkTestCar(t, srv, "KA-01-0001")
+	expectStatus(t, serve(t, srv, http.MethodDelete, "/floors/0?drain=true", ""), http.StatusOK)
+	// floor 0 drains until its car leaves
+	expectStatus(t, serve(t, srv, http.MethodPost, "/slots/0/0/enable", ""), http.StatusConflict)
+}
+
+func TestServerWaitlistRejectsLargeVehicles(t *testing.T) {
+	srv, _, _ := newTestServer(t)
+	expectStatus(t, serve(t, srv, http.MethodPost, "/waitlist", `{"registrationNumber": "KA-01-0001", "type": "Bus"}`), http.StatusBadRequest)
+	expectStatus(t, serve(t, srv, http.MethodPost, "/waitlist", `{"registrationNumber": "KA-01-0001", "type": "Car"}`), http.StatusCreated)
+}
+
+func TestServerHistoryCSV(t *testing.T) {
+	srv, clock, _ := newTestServer(t)
+	ticket := parkTestCar(t, srv, "KA-01-0001")
+	clock.Advance(time.Hour)
+	expectStatus(t, serve(t, srv, http.MethodPost, "/tickets/"+ticket.Id+"/checkout", `{"method": "card", "account": "4111"}`), http.StatusOK)
+
+	rec := serve(t, srv, http.MethodGet, "/reports/history.csv?from=2024-03-04&to=2024-03-05", "")
+	expectStatus(t, rec, http.StatusOK)
+	rows, err := csv.NewReader(rec.Body).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec.Header().Get("Content-Type") != "text/csv" || len(rows) != 2 || rows[1][0] != ticket.Id {
+		t.Fatalf("history csv %v", rows)
+	}
+	expectStatus(t, serve(t, srv, http.MethodGet, "/reports/history.csv?from=yesterday", ""), http.StatusBadRequest)
+}
@@ -0,0 +1,425 @@
+package main
+
+import (
+	"container/heap"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"math/rand/v2"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Distribution draws durations, e.g. the gap between two arrivals at a gate or a stay
+type Distribution interface {
+	Draw(r *rand.Rand) time.Duration
+}
+
+// Fixed always draws the same duration
+type Fixed time.Duration
+
+func (d Fixed) Draw(*rand.Rand) time.Duration {
+	return time.Duration(d)
+}
+
+// Uniform draws in [Min, Max]
+type Uniform struct {
+	Min time.Duration
+	Max time.Duration
+}
+
+func (d Uniform) Draw(r *rand.Rand) time.Duration {
+	if d.Max <= d.Min {
+		return d.Min
+	}
+	return d.Min + time.Duration(r.Int64N(int64(d.Max-d.Min)+1))
+}
+
+// Exponential draws around Mean, as gaps between arrivals it gives a Poisson arrival process
+type Exponential struct {
+	Mean time.Duration
+}
+
+func (d Exponential) Draw(r *rand.Rand) time.Duration {
+	return time.Duration(r.ExpFloat64() * float64(d.Mean))
+}
+
+// SimulationConfig describes the traffic of a simulation
+type SimulationConfig struct {
+	EntryGates int
+	ExitGates  int
+	// Duration is the virtual time simulated, the clock moves by Tick (default 1m) and the gates
+	// handle everything due by the new time concurrently
+	Duration time.Duration
+	Tick     time.Duration
+	// Arrival is the gap between two vehicles at one entry gate, Stay is how long a vehicle parks
+	Arrival Distribution
+	Stay    Distribution
+	// VehicleMix weights the vehicle types of the arrivals, default Car only
+	VehicleMix map[VehicleType]int
+	// Seed makes the traffic reproducible, the slots given out still depend on the scheduling of the gates
+	Seed uint64
+	// Operators act on the lot next to the gates, e.g. reservations, maintenance or reports
+	Operators []Operator
+}
+
+// Operator is someone else using the lot during a simulation, its own vehicles do not go through the gates
+type Operator interface {
+	// Tick runs every tick, concurrently with the gates
+	Tick(now time.Time)
+	// Finish runs once the traffic is over, before the vehicles left inside go out : every slot
+	// the operator holds, disabled or parks on has to be handed back, the lot is then checked to be empty
+	Finish(now time.Time)
+}
+
+// SimulationReport sums up a run, throughputs are per virtual hour
+type SimulationReport struct {
+	Duration   time.Duration
+	Arrivals   int
+	Parked     int
+	Rejected   int
+	Departures int
+	// Throughput -> vehicles parked, ExitThroughput -> vehicles that left
+	Throughput     float64
+	ExitThroughput float64
+	RejectionRate  float64
+	// Utilisation is the average share of the enabled slots occupied per slot type, sampled every tick
+	Utilisation map[VehicleType]float64
+	// Violations lists every broken invariant once : double allocated slots, lost slots, failed checkouts
+	Violations []string
+	// WallTime is how long the run really took
+	WallTime time.Duration
+}
+
+// departure is a vehicle due to leave at an exit gate
+type departure struct {
+	at       time.Time
+	ticketID string
+}
+
+// departures is a min heap on the departure time
+type departures []departure
+
+func (d departures) Len() int           { return len(d) }
+func (d departures) Less(i, j int) bool { return d[i].at.Before(d[j].at) }
+func (d departures) Swap(i, j int)      { d[i], d[j] = d[j], d[i] }
+func (d *departures) Push(x any)        { *d = append(*d, x.(departure)) }
+func (d *departures) Pop() any {
+	old := *d
+	last := old[len(old)-1]
+	*d = old[:len(old)-1]
+	return last
+}
+
+// simulation is the state shared by the gates of a run
+type simulation struct {
+	lot   *ParkingLot
+	clock *FakeClock
+	cfg   SimulationConfig
+	mix   []VehicleType
+
+	queueLock sync.Mutex
+	queue     departures
+
+	arrivals   atomic.Int64
+	parked     atomic.Int64
+	rejected   atomic.Int64
+	departures atomic.Int64
+
+	violationLock sync.Mutex
+	violations    []string
+	seen          map[string]bool
+}
+
+func (cfg SimulationConfig) validate() error {
+	switch {
+	case cfg.EntryGates <= 0 || cfg.ExitGates <= 0:
+		return errors.New("a simulation needs at least one entry and one exit gate")
+	case cfg.Duration <= 0 || cfg.Tick < 0:
+		return errors.New("invalid simulation duration")
+	case cfg.Arrival == nil || cfg.Stay == nil:
+		return errors.New("arrival and stay distributions are required")
+	}
+	for vtype, weight := range cfg.VehicleMix {
+		if weight < 0 || !slices.Contains(vehicleTypes, vtype) {
+			return fmt.Errorf("invalid vehicle mix for %s", vtype.ToString())
+		}
+	}
+	return nil
+}
+
+// Simulate runs the gates and the operators against lot, whose clock has to be clock. Once the operators
+// finished, every vehicle still inside leaves, the lot is then checked to be empty
+func Simulate(lot *ParkingLot, clock *FakeClock, cfg SimulationConfig) (SimulationReport, error) {
+	if err := cfg.validate(); err != nil {
+		return SimulationReport{}, err
+	}
+	// the exit gates unpark without paying
+	if lot.payments != nil {
+		return SimulationReport{}, fmt.Errorf("%w: simulate a lot without payment processor", ErrPaymentRequired)
+	}
+	if cfg.Tick == 0 {
+		cfg.Tick = time.Minute
+	}
+	if len(cfg.VehicleMix) == 0 {
+		cfg.VehicleMix = map[VehicleType]int{Car: 1}
+	}
+	sim := &simulation{lot: lot, clock: clock, cfg: cfg, seen: make(map[string]bool)}
+	for _, vtype := range slices.Sorted(maps.Keys(cfg.VehicleMix)) {
+		for range cfg.VehicleMix[vtype] {
+			sim.mix = append(sim.mix, vtype)
+		}
+	}
+	if len(sim.mix) == 0 {
+		return SimulationReport{}, errors.New("vehicle mix has no weight")
+	}
+
+	started := time.Now()
+	start := clock.Now()
+	end := start.Add(cfg.Duration)
+	gates := make([]chan time.Time, 0, cfg.EntryGates+cfg.ExitGates)
+	var tick sync.WaitGroup
+	for i := range cfg.EntryGates {
+		gates = append(gates, sim.gate(&tick, sim.entryGate(i, start)))
+	}
+	for range cfg.ExitGates {
+		gates = append(gates, sim.gate(&tick, sim.exitGate))
+	}
+	for _, op := range cfg.Operators {
+		gates = append(gates, sim.gate(&tick, op.Tick))
+	}
+	defer func() {
+		for _, gate := range gates {
+			close(gate)
+		}
+	}()
+
+	utilisation := make(map[VehicleType]float64)
+	samples := 0
+	for now := start; now.Before(end); {
+		now = now.Add(cfg.Tick)
+		clock.Set(now)
+		tick.Add(len(gates))
+		for _, gate := range gates {
+			gate <- now
+		}
+		tick.Wait()
+		for vtype, used := range sim.checkInvariants() {
+			utilisation[vtype] += used
+		}
+		samples++
+	}
+	for vtype := range utilisation {
+		utilisation[vtype] /= float64(samples)
+	}
+
+	for _, op := range cfg.Operators {
+		op.Finish(end)
+	}
+	// everybody left inside goes out through the exit gates
+	sim.queueLock.Lock()
+	last := end
+	for _, d := range sim.queue {
+		last = maxTime(last, d.at)
+	}
+	sim.queueLock.Unlock()
+	clock.Set(last)
+	tick.Add(cfg.ExitGates)
+	for _, gate := range gates[cfg.EntryGates : cfg.EntryGates+cfg.ExitGates] {
+		gate <- last
+	}
+	tick.Wait()
+	sim.checkInvariants()
+	sim.checkEmpty()
+
+	hours := cfg.Duration.Hours()
+	report := SimulationReport{
+		Duration:       cfg.Duration,
+		Arrivals:       int(sim.arrivals.Load()),
+		Parked:         int(sim.parked.Load()),
+		Rejected:       int(sim.rejected.Load()),
+		Departures:     int(sim.departures.Load()),
+		Throughput:     float64(sim.parked.Load()) / hours,
+		ExitThroughput: float64(sim.departures.Load()) / hours,
+		Utilisation:    utilisation,
+		Violations:     sim.violations,
+		WallTime:       time.Since(started),
+	}
+	if report.Arrivals > 0 {
+		report.RejectionRate = float64(report.Rejected) / float64(report.Arrivals)
+	}
+	return report, nil
+}
+
+func maxTime(a time.Time, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+// gate runs handle in its own goroutine for every tick sent on the returned channel
+func (sim *simulation) gate(tick *sync.WaitGroup, handle func(now time.Time)) chan time.Time {
+	ticks := make(chan time.Time)
+	go func() {
+		for now := range ticks {
+			handle(now)
+			tick.Done()
+		}
+	}()
+	return ticks
+}
+
+// entryGate checks in the vehicles arrived by now, each gate draws its own traffic
+func (sim *simulation) entryGate(gate int, start time.Time) func(now time.Time) {
+	r := rand.New(rand.NewPCG(sim.cfg.Seed, uint64(gate)))
+	next := start.Add(sim.cfg.Arrival.Draw(r))
+	seq := 0
+	return func(now time.Time) {
+		for !next.After(now) {
+			seq++
+			vehicle := Vehicle{
+				RegistrationNumber: "SIM-" + strconv.Itoa(gate) + "-" + strconv.Itoa(seq),
+				Type:               sim.mix[r.IntN(len(sim.mix))],
+			}
+			sim.arrivals.Add(1)
+			ticket, err := sim.lot.CheckIn(vehicle)
+			switch {
+			case errors.Is(err, ErrNoSlotAvailable):
+				sim.rejected.Add(1)
+			case err != nil:
+				sim.violation(fmt.Sprintf("check in of %s failed: %v", vehicle.RegistrationNumber, err))
+			default:
+				sim.parked.Add(1)
+				sim.queueLock.Lock()
+				heap.Push(&sim.queue, departure{at: now.Add(sim.cfg.Stay.Draw(r)), ticketID: ticket.Id})
+				sim.queueLock.Unlock()
+			}
+			// a zero gap would never let the gate move on
+			next = next.Add(max(sim.cfg.Arrival.Draw(r), time.Nanosecond))
+		}
+	}
+}
+
+// exitGate checks out the vehicles due by now, the exit gates share the departures
+func (sim *simulation) exitGate(now time.Time) {
+	for {
+		sim.queueLock.Lock()
+		if len(sim.queue) == 0 || sim.queue[0].at.After(now) {
+			sim.queueLock.Unlock()
+			return
+		}
+		d := heap.Pop(&sim.queue).(departure)
+		sim.queueLock.Unlock()
+
+		if _, err := sim.lot.Unpark(d.ticketID); err != nil {
+			sim.violation(fmt.Sprintf("checkout of %s failed: %v", d.ticketID, err))
+			continue
+		}
+		sim.departures.Add(1)
+	}
+}
+
+func (sim *simulation) violation(msg string) {
+	sim.violationLock.Lock()
+	defer sim.violationLock.Unlock()
+	if !sim.seen[msg] {
+		sim.seen[msg] = true
+		sim.violations = append(sim.violations, msg)
+	}
+}
+
+// checkInvariants checks every slot against the active tickets and the availability index while
+// the gates are idle, and returns the share of the enabled slots occupied per slot type
+func (sim *simulation) checkInvariants() map[VehicleType]float64 {
+	pl := sim.lot
+	pl.lockAll()
+	defer pl.unlockAll()
+
+	owners := make(map[*Slot]string)
+	pl.ticketLock.RLock()
+	for _, ticket := range pl.ticketStore {
+		if ticket.Status == TicketClosed {
+			continue
+		}
+		for _, slot := range ticket.occupiedSlots() {
+			if other, taken := owners[slot]; taken {
+				sim.violation(fmt.Sprintf("slot %d-%d double allocated to %s and %s", slot.FloorId, slot.Id, other, ticket.Id))
+				continue
+			}
+			owners[slot] = ticket.Id
+		}
+	}
+	pl.ticketLock.RUnlock()
+
+	listed := make(map[*Slot]bool)
+	for _, pool := range pl.availableSlots {
+		for _, slot := range pool {
+			if listed[slot] {
+				sim.violation(fmt.Sprintf("slot %d-%d listed twice as available", slot.FloorId, slot.Id))
+			}
+			listed[slot] = true
+		}
+	}
+
+	occupied := make(map[VehicleType]int)
+	for _, slot := range pl.slotStore {
+		owner, owned := owners[slot]
+		switch {
+		case slot.IsOccupied && !owned:
+			sim.violation(fmt.Sprintf("slot %d-%d lost: occupied without an active ticket", slot.FloorId, slot.Id))
+		case !slot.IsOccupied && owned:
+			sim.violation(fmt.Sprintf("slot %d-%d free while ticket %s is active", slot.FloorId, slot.Id, owner))
+		case slot.available() && !listed[slot]:
+			sim.violation(fmt.Sprintf("slot %d-%d lost: free but not available", slot.FloorId, slot.Id))
+		case !slot.available() && listed[slot]:
+			sim.violation(fmt.Sprintf("slot %d-%d listed as available while taken", slot.FloorId, slot.Id))
+		}
+		if slot.IsOccupied && !slot.Disabled {
+			occupied[slot.Type]++
+		}
+	}
+
+	utilisation := make(map[VehicleType]float64)
+	for vtype, count := range pl.slotCount {
+		if count > 0 {
+			utilisation[vtype] = float64(occupied[vtype]) / float64(count)
+		}
+	}
+	return utilisation
+}
+
+// checkEmpty expects every slot back in the availability index once all vehicles left
+func (sim *simulation) checkEmpty() {
+	pl := sim.lot
+	pl.lockAll()
+	defer pl.unlockAll()
+	for _, vtype := range slotTypes {
+		if free := len(pl.availableSlots[vtype]); free != pl.slotCount[vtype] {
+			sim.violation(fmt.Sprintf("%d of %d %s slots available once empty", free, pl.slotCount[vtype], vtype.ToString()))
+		}
+	}
+}
+
+// Print writes the report in a human readable form
+func (r SimulationReport) Print(w io.Writer) {
+	fmt.Fprintf(w, "Simulated %s in %s\n", r.Duration, r.WallTime.Round(time.Millisecond))
+	fmt.Fprintf(w, "Arrivals: %d, parked: %d, rejected: %d (%.1f%%), departures: %d\n",
+		r.Arrivals, r.Parked, r.Rejected, 100*r.RejectionRate, r.Departures)
+	fmt.Fprintf(w, "Throughput: %.1f in / %.1f out per hour\n", r.Throughput, r.ExitThroughput)
+	for _, vtype := range slices.Sorted(maps.Keys(r.Utilisation)) {
+		fmt.Fprintf(w, "Utilisation %s: %.1f%%\n", vtype.ToString(), 100*r.Utilisation[vtype])
+	}
+	if len(r.Violations) == 0 {
+		fmt.Fprintln(w, "No invariant violation")
+		return
+	}
+	fmt.Fprintf(w, "%d invariant violations:\n", len(r.Violations))
+	for _, v := range r.Violations {
+		fmt.Fprintln(w, " ", v)
+	}
+}
@@ -0,0 +1,338 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"math/rand/v2"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestSimulateBusyLot(t *testing.T) {
+	clock := NewFakeClock(manualClockStart)
+	lot, err := NewParkingLot(DefaultLayout(2, 8), &NormalPricing{Clock: clock}, WithClock(clock))
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, err := Simulate(lot, clock, SimulationConfig{
+		EntryGates: 4,
+		ExitGates:  2,
+		Duration:   12 * time.Hour,
+		Arrival:    Exponential{Mean: 5 * time.Minute},
+		Stay:       Uniform{Min: 10 * time.Minute, Max: 3 * time.Hour},
+		VehicleMix: map[VehicleType]int{Bike: 1, Car: 3, Truck: 1, Bus: 1},
+		Seed:       7,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Violations) != 0 {
+		t.Fatalf("violations: %v", report.Violations)
+	}
+	// the traffic overflows the lot, every vehicle let in leaves by the end
+	if report.Rejected == 0 || report.Parked == 0 {
+		t.Fatalf("report %+v, want both parked and rejected vehicles", report)
+	}
+	if report.Arrivals != report.Parked+report.Rejected || report.Departures != report.Parked {
+		t.Fatalf("report %+v does not add up", report)
+	}
+	for vtype, used := range report.Utilisation {
+		if used <= 0 || used > 1 {
+			t.Errorf("utilisation of %s = %v", vtype.ToString(), used)
+		}
+	}
+	if free := lot.FreeSlotCount(Car); free != 8 {
+		t.Fatalf("%d free Car slots after the run, want 8", free)
+	}
+}
+
+func TestSimulationConfigValidation(t *testing.T) {
+	valid := SimulationConfig{EntryGates: 1, ExitGates: 1, Duration: time.Hour, Arrival: Fixed(time.Minute), Stay: Fixed(time.Minute)}
+	for name, broken := range map[string]func(*SimulationConfig){
+		"no entry gate":      func(cfg *SimulationConfig) { cfg.EntryGates = 0 },
+		"no exit gate":       func(cfg *SimulationConfig) { cfg.ExitGates = 0 },
+		"no duration":        func(cfg *SimulationConfig) { cfg.Duration = 0 },
+		"no arrivals":        func(cfg *SimulationConfig) { cfg.Arrival = nil },
+		"negative weight":    func(cfg *SimulationConfig) { cfg.VehicleMix = map[VehicleType]int{Car: -1} },
+		"mix without weight": func(cfg *SimulationConfig) { cfg.VehicleMix = map[VehicleType]int{Car: 0} },
+	} {
+		cfg := valid
+		broken(&cfg)
+		clock := NewFakeClock(manualClockStart)
+		lot, err := NewParkingLot(DefaultLayout(1, 6), &NormalPricing{Clock: clock}, WithClock(clock))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Simulate(lot, clock, cfg); err == nil {
+			t.Errorf("%s: Simulate accepted the config", name)
+		}
+	}
+}
+
+func TestDistributions(t *testing.T) {
+	r := rand.New(rand.NewPCG(1, 2))
+	if got := Fixed(time.Minute).Draw(r); got != time.Minute {
+		t.Fatalf("Fixed drew %v", got)
+	}
+	if got := (Uniform{Min: time.Hour, Max: time.Minute}).Draw(r); got != time.Hour {
+		t.Fatalf("Uniform with Max below Min drew %v, want Min", got)
+	}
+	for range 1000 {
+		if got := (Uniform{Min: time.Minute, Max: 2 * time.Minute}).Draw(r); got < time.Minute || got > 2*time.Minute {
+			t.Fatalf("Uniform drew %v out of [1m, 2m]", got)
+		}
+		if got := (Exponential{Mean: time.Minute}).Draw(r); got < 0 {
+			t.Fatalf("Exponential drew %v", got)
+		}
+	}
+}
+
+// mixedOperator books & checks in reservations, waits on the waitlist, takes Car slots out for maintenance
+// and pulls the reports while the gates run. Tick & Finish run one at a time, its own state needs no lock
+type mixedOperator struct {
+	t     *testing.T
+	lot   *ParkingLot
+	r     *rand.Rand
+	start time.Time
+	seq   int
+
+	reservations map[string]Vehicle
+	waiting      []string
+	disabled     map[[2]int]time.Time
+	// tickets -> when the operator's own vehicles leave
+	tickets map[string]time.Time
+	// done counts the operations that went through, by kind
+	done map[string]int
+}
+
+func newMixedOperator(t *testing.T, lot *ParkingLot, start time.Time) *mixedOperator {
+	return &mixedOperator{
+		t:            t,
+		lot:          lot,
+		r:            rand.New(rand.NewPCG(7, 7)),
+		start:        start,
+		reservations: make(map[string]Vehicle),
+		disabled:     make(map[[2]int]time.Time),
+		tickets:      make(map[string]time.Time),
+		done:         make(map[string]int),
+	}
+}
+
+func (op *mixedOperator) vehicle(prefix string) Vehicle {
+	op.seq++
+	return Vehicle{RegistrationNumber: fmt.Sprintf("OP-%s-%d", prefix, op.seq), Type: Car}
+}
+
+func (op *mixedOperator) park(ticket ParkingTicket, now time.Time) {
+	op.tickets[ticket.Id] = now.Add(time.Duration(10+op.r.IntN(50)) * time.Minute)
+}
+
+func (op *mixedOperator) Tick(now time.Time) {
+	op.lot.ProcessReservations()
+	op.reserve(now)
+	op.waitlist(now)
+	op.maintenance(now)
+	op.leave(now, false)
+	if op.r.IntN(10) == 0 {
+		op.reports(now)
+	}
+}
+
+func (op *mixedOperator) reserve(now time.Time) {
+	if op.r.IntN(4) == 0 {
+		from := now.Add(time.Duration(op.r.IntN(30)) * time.Minute)
+		reservation, err := op.lot.Reserve(Car, from, from.Add(time.Hour))
+		switch {
+		case errors.Is(err, ErrNoCapacity):
+		case err != nil:
+			op.t.Errorf("Reserve: %v", err)
+		default:
+			op.reservations[reservation.Id] = op.vehicle("R")
+		}
+	}
+	for _, id := range slices.Sorted(maps.Keys(op.reservations)) {
+		reservation, err := op.lot.GetReservation(id)
+		if err != nil {
+			op.t.Errorf("GetReservation(%s): %v", id, err)
+			continue
+		}
+		switch {
+		case !reservation.Status.open():
+			delete(op.reservations, id)
+		case op.r.IntN(20) == 0:
+			if err := op.lot.CancelReservation(id); err != nil && !errors.Is(err, ErrReservationClosed) {
+				op.t.Errorf("CancelReservation(%s): %v", id, err)
+			}
+			delete(op.reservations, id)
+		case reservation.Status == ReservationHeld && op.r.IntN(3) > 0:
+			// the gates may turn it into a no-show meanwhile
+			ticket, err := op.lot.CheckInReservation(id, op.reservations[id])
+			if err != nil && !errors.Is(err, ErrReservationClosed) && !errors.Is(err, ErrNoSlotAvailable) {
+				op.t.Errorf("CheckInReservation(%s): %v", id, err)
+			}
+			if err == nil {
+				op.park(ticket, now)
+				op.done["reservation"]++
+				delete(op.reservations, id)
+			}
+		}
+	}
+}
+
+func (op *mixedOperator) waitlist(now time.Time) {
+	if op.r.IntN(5) == 0 {
+		entry, err := op.lot.JoinWaitlist(op.vehicle("W"))
+		if err != nil {
+			op.t.Errorf("JoinWaitlist: %v", err)
+		} else {
+			op.waiting = append(op.waiting, entry.Id)
+		}
+	}
+	waiting := op.waiting[:0]
+	for _, id := range op.waiting {
+		status, err := op.lot.WaitlistStatus(id)
+		switch {
+		case errors.Is(err, ErrWaitlistNotFound):
+			// the hold timed out
+			continue
+		case err != nil:
+			op.t.Errorf("WaitlistStatus(%s): %v", id, err)
+			continue
+		case op.r.IntN(30) == 0:
+			if err := op.lot.LeaveWaitlist(id); err != nil {
+				op.t.Errorf("LeaveWaitlist(%s): %v", id, err)
+			}
+			continue
+		case status.Hold != nil && op.r.IntN(2) == 0:
+			ticket, err := op.lot.CheckInWaitlist(id)
+			if err != nil {
+				op.t.Errorf("CheckInWaitlist(%s): %v", id, err)
+			} else {
+				op.park(ticket, now)
+				op.done["waitlist"]++
+			}
+			continue
+		}
+		waiting = append(waiting, id)
+	}
+	op.waiting = waiting
+}
+
+func (op *mixedOperator) maintenance(now time.Time) {
+	for key, until := range op.disabled {
+		if !now.Before(until) {
+			if err := op.lot.EnableSlot(key[0], key[1]); err != nil {
+				op.t.Errorf("EnableSlot(%d, %d): %v", key[0], key[1], err)
+			}
+			delete(op.disabled, key)
+		}
+	}
+	if op.r.IntN(8) != 0 {
+		return
+	}
+	// any Car slot, held ones included : occupied ones are refused
+	slots := op.lot.FreeSlots(Car)
+	for floor, ids := range op.lot.OccupiedSlots(Car) {
+		slots[floor] = append(slots[floor], ids...)
+	}
+	floors := slices.Sorted(maps.Keys(slots))
+	floor := floors[op.r.IntN(len(floors))]
+	if len(slots[floor]) == 0 {
+		return
+	}
+	id := slots[floor][op.r.IntN(len(slots[floor]))]
+	if _, disabled := op.disabled[[2]int{floor, id}]; disabled {
+		return
+	}
+	if err := op.lot.DisableSlot(floor, id, RefuseOccupied); err != nil && !errors.Is(err, ErrSlotOccupied) {
+		op.t.Errorf("DisableSlot(%d, %d): %v", floor, id, err)
+	} else if err == nil {
+		op.disabled[[2]int{floor, id}] = now.Add(time.Duration(5+op.r.IntN(30)) * time.Minute)
+		op.done["maintenance"]++
+	}
+}
+
+// leave unparks the operator's vehicles due by now, all of them with everyone
+func (op *mixedOperator) leave(now time.Time, everyone bool) {
+	for _, id := range slices.Sorted(maps.Keys(op.tickets)) {
+		if !everyone && now.Before(op.tickets[id]) {
+			continue
+		}
+		if _, err := op.lot.Unpark(id); err != nil {
+			op.t.Errorf("Unpark(%s): %v", id, err)
+		}
+		delete(op.tickets, id)
+	}
+}
+
+func (op *mixedOperator) reports(now time.Time) {
+	op.lot.Revenue(op.start, now)
+	op.lot.PeakOccupancy(op.start, now)
+	op.lot.History(op.start, now)
+	op.lot.Occupancy(Car)
+	op.lot.OccupiedSlots(Car)
+	if err := op.lot.ExportCSV(io.Discard, op.start, now); err != nil {
+		op.t.Errorf("ExportCSV: %v", err)
+	}
+}
+
+func (op *mixedOperator) Finish(now time.Time) {
+	for id := range op.reservations {
+		if err := op.lot.CancelReservation(id); err != nil && !errors.Is(err, ErrReservationClosed) {
+			op.t.Errorf("CancelReservation(%s): %v", id, err)
+		}
+	}
+	for _, id := range op.waiting {
+		if err := op.lot.LeaveWaitlist(id); err != nil && !errors.Is(err, ErrWaitlistNotFound) {
+			op.t.Errorf("LeaveWaitlist(%s): %v", id, err)
+		}
+	}
+	for key := range op.disabled {
+		if err := op.lot.EnableSlot(key[0], key[1]); err != nil {
+			op.t.Errorf("EnableSlot(%d, %d): %v", key[0], key[1], err)
+		}
+	}
+	op.leave(now, true)
+	op.reports(now)
+}
+
+// the gates park & unpark while reservations, the waitlist, maintenance and the reports run next to them,
+// run it under go test -race
+func TestSimulateWithOperators(t *testing.T) {
+	clock := NewFakeClock(manualClockStart)
+	lot, err := NewParkingLot(DefaultLayout(2, 16), &NormalPricing{Clock: clock}, WithClock(clock))
+	if err != nil {
+		t.Fatal(err)
+	}
+	op := newMixedOperator(t, lot, manualClockStart)
+	report, err := Simulate(lot, clock, SimulationConfig{
+		EntryGates: 2,
+		ExitGates:  2,
+		Duration:   6 * time.Hour,
+		Arrival:    Exponential{Mean: 3 * time.Minute},
+		Stay:       Uniform{Max: 90 * time.Minute},
+		VehicleMix: map[VehicleType]int{Bike: 2, Car: 5, Truck: 1},
+		Seed:       42,
+		Operators:  []Operator{op},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Violations) > 0 {
+		t.Fatalf("violations:\n%v", report.Violations)
+	}
+	if report.Parked == 0 || report.Departures != report.Parked {
+		t.Fatalf("parked %d, departures %d", report.Parked, report.Departures)
+	}
+	for _, kind := range []string{"reservation", "waitlist", "maintenance"} {
+		if op.done[kind] == 0 {
+			t.Errorf("no %s went through", kind)
+		}
+	}
+	if report.Rejected == 0 {
+		t.Fatalf("the lot was never full, the operator did not compete for slots")
+	}
+}
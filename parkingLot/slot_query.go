@@ -0,0 +1,161 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Occupancy counts the slots of one vehicle type
+type Occupancy struct {
+	Free     int
+	Occupied int
+}
+
+func (o Occupancy) Total() int {
+	return o.Free + o.Occupied
+}
+
+// GetAvailableSlots returns a copy of the free slots of vtype, sorted by floor & slot
+func (pl *ParkingLot) GetAvailableSlots(vtype VehicleType) []Slot {
+	lock := pl.getLock(vtype)
+	lock.RLock()
+	defer lock.RUnlock()
+
+	available := pl.freeSlots(vtype)
+	slots := make([]Slot, len(available))
+	for i, slot := range available {
+		slots[i] = *slot
+	}
+	return slots
+}
+
+// FreeSlots returns floor -> free slot ids for vtype
+func (pl *ParkingLot) FreeSlots(vtype VehicleType) map[int][]int {
+	return pl.slotsByFloor(vtype, false)
+}
+
+// OccupiedSlots returns floor -> occupied slot ids for vtype
+func (pl *ParkingLot) OccupiedSlots(vtype VehicleType) map[int][]int {
+	return pl.slotsByFloor(vtype, true)
+}
+
+// FreeSlotCount returns the number of free slots for vtype across all floors
+func (pl *ParkingLot) FreeSlotCount(vtype VehicleType) int {
+	lock := pl.getLock(vtype)
+	lock.RLock()
+	defer lock.RUnlock()
+
+	return len(pl.freeSlots(vtype))
+}
+
+// Occupancy returns free & occupied counts for vtype across all floors
+func (pl *ParkingLot) Occupancy(vtype VehicleType) Occupancy {
+	var total Occupancy
+	for _, occ := range pl.OccupancyByFloor(vtype) {
+		total.Free += occ.Free
+		total.Occupied += occ.Occupied
+	}
+	return total
+}
+
+// OccupancyByFloor returns floor -> free & occupied counts for vtype,
+// floors without any slot of vtype are left out
+func (pl *ParkingLot) OccupancyByFloor(vtype VehicleType) map[int]Occupancy {
+	lock := pl.getLock(vtype)
+	lock.RLock()
+	defer lock.RUnlock()
+
+	floors := make(map[int]Occupancy)
+	for _, slot := range pl.slotStore {
+		if slot.GetVehicleType() != vtype {
+			continue
+		}
+		occ := floors[slot.FloorId]
+		switch {
+		case slot.IsOccupied:
+			occ.Occupied++
+		case slot.available():
+			occ.Free++
+		}
+		floors[slot.FloorId] = occ
+	}
+	return floors
+}
+
+// slotsByFloor walks the slot store once, every floor having a slot of vtype gets an entry (possibly empty)
+func (pl *ParkingLot) slotsByFloor(vtype VehicleType, occupied bool) map[int][]int {
+	lock := pl.getLock(vtype)
+	lock.RLock()
+	defer lock.RUnlock()
+
+	floors := make(map[int][]int)
+	for _, slot := range pl.slotStore {
+		if slot.GetVehicleType() != vtype {
+			continue
+		}
+		ids, seen := floors[slot.FloorId]
+		if !seen {
+			ids = []int{}
+		}
+		if (occupied && slot.IsOccupied) || (!occupied && slot.available()) {
+			ids = append(ids, slot.Id)
+		}
+		floors[slot.FloorId] = ids
+	}
+	for floor := range floors {
+		slices.Sort(floors[floor])
+	}
+	return floors
+}
+
+// DisplayFreeSlots renders
+//
+//	Free slots for CAR:
+//	Floor 1 : 2, 5
+//	Floor 2 : 3
+func (pl *ParkingLot) DisplayFreeSlots(vtype VehicleType) string {
+	return renderSlots("Free slots for "+strings.ToUpper(vtype.ToString())+":", pl.FreeSlots(vtype))
+}
+
+// DisplayOccupiedSlots renders the occupied slots in the same format as DisplayFreeSlots
+func (pl *ParkingLot) DisplayOccupiedSlots(vtype VehicleType) string {
+	return renderSlots("Occupied slots for "+strings.ToUpper(vtype.ToString())+":", pl.OccupiedSlots(vtype))
+}
+
+// DisplayFreeSlotCount renders one "Floor 1 : 2" line per floor
+func (pl *ParkingLot) DisplayFreeSlotCount(vtype VehicleType) string {
+	occupancy := pl.OccupancyByFloor(vtype)
+	var b strings.Builder
+	b.WriteString("Free slot count for " + strings.ToUpper(vtype.ToString()) + ":")
+	for _, floor := range sortedFloors(occupancy) {
+		fmt.Fprintf(&b, "\nFloor %d : %d", floor, occupancy[floor].Free)
+	}
+	return b.String()
+}
+
+func renderSlots(header string, floors map[int][]int) string {
+	var b strings.Builder
+	b.WriteString(header)
+	for _, floor := range sortedFloors(floors) {
+		ids := make([]string, len(floors[floor]))
+		for i, id := range floors[floor] {
+			ids[i] = strconv.Itoa(id)
+		}
+		fmt.Fprintf(&b, "\nFloor %d :", floor)
+		if len(ids) > 0 {
+			b.WriteString(" " + strings.Join(ids, ", "))
+		}
+	}
+	return b.String()
+}
+
+func sortedFloors[V any](floors map[int]V) []int {
+	keys := make([]int, 0, len(floors))
+	for floor := range floors {
+		keys = append(keys, floor)
+	}
+	slices.Sort(keys)
+	return keys
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestSlotQueriesByFloor(t *testing.T) {
+	// Car slots on both floors : 0 & 5
+	lot, err := NewParkingLot(DefaultLayout(2, 6), &NormalPricing{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, registration := range []string{"KA-01-0001", "KA-01-0002"} {
+		if _, err := lot.CheckIn(Vehicle{RegistrationNumber: registration, Type: Car}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if got, want := lot.FreeSlots(Car), map[int][]int{0: nil, 1: {0, 5}}; !sameFloors(got, want) {
+		t.Errorf("FreeSlots(Car) = %v, want %v", got, want)
+	}
+	if got, want := lot.OccupiedSlots(Car), map[int][]int{0: {0, 5}, 1: nil}; !sameFloors(got, want) {
+		t.Errorf("OccupiedSlots(Car) = %v, want %v", got, want)
+	}
+	if got := lot.FreeSlotCount(Car); got != 2 {
+		t.Errorf("FreeSlotCount(Car) = %d, want 2", got)
+	}
+	if got, want := lot.Occupancy(Car), (Occupancy{Free: 2, Occupied: 2}); got != want {
+		t.Errorf("Occupancy(Car) = %+v, want %+v", got, want)
+	}
+	if got, want := lot.OccupancyByFloor(Bike), map[int]Occupancy{0: {Free: 3}, 1: {Free: 3}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("OccupancyByFloor(Bike) = %v, want %v", got, want)
+	}
+
+	if got, want := lot.DisplayFreeSlots(Car), "Free slots for CAR:\nFloor 0 :\nFloor 1 : 0, 5"; got != want {
+		t.Errorf("DisplayFreeSlots(Car) = %q, want %q", got, want)
+	}
+	if got, want := lot.DisplayOccupiedSlots(Car), "Occupied slots for CAR:\nFloor 0 : 0, 5\nFloor 1 :"; got != want {
+		t.Errorf("DisplayOccupiedSlots(Car) = %q, want %q", got, want)
+	}
+	if got, want := lot.DisplayFreeSlotCount(Truck), "Free slot count for TRUCK:\nFloor 0 : 1\nFloor 1 : 1"; got != want {
+		t.Errorf("DisplayFreeSlotCount(Truck) = %q, want %q", got, want)
+	}
+}
+
+// sameFloors compares floor -> slot ids, an empty floor may be nil or empty
+func sameFloors(got map[int][]int, want map[int][]int) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for floor, ids := range want {
+		other, exists := got[floor]
+		if !exists || len(other) != len(ids) || (len(ids) > 0 && !reflect.DeepEqual(other, ids)) {
+			return false
+		}
+	}
+	return true
+}
+
+// the availability index is one map shared by every pool : reading the Bike pool while the Car & Truck
+// pools park and unpark must not race (go test -race)
+func TestSlotQueriesDuringParking(t *testing.T) {
+	lot, err := NewParkingLot(DefaultLayout(2, 8), &NormalPricing{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bikes := lot.FreeSlotCount(Bike)
+
+	var wg sync.WaitGroup
+	for i, vtype := range []VehicleType{Car, Truck} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for n := range 200 {
+				ticket, err := lot.CheckIn(Vehicle{RegistrationNumber: fmt.Sprintf("KA-%d-%d", i, n), Type: vtype})
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if _, err := lot.Unpark(ticket.Id); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for range 200 {
+			if got := len(lot.GetAvailableSlots(Bike)); got != bikes {
+				t.Errorf("GetAvailableSlots(Bike) = %d slots, want %d", got, bikes)
+			}
+			if got := lot.FreeSlotCount(Bike); got != bikes {
+				t.Errorf("FreeSlotCount(Bike) = %d, want %d", got, bikes)
+			}
+		}
+	}()
+	wg.Wait()
+}
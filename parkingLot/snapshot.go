@@ -0,0 +1,348 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+)
+
+// 2 -> tickets carry billedAs, version 1 tickets are billed at their vehicle type
+// 3 -> reservations, draining floors, payments & paid time, older snapshots simply have none.
+// Tickets carry their visit
+const snapshotVersion = 3
+
+var ErrSnapshotVersion = errors.New("unsupported snapshot version")
+
+type slotState struct {
+	FloorId  int         `json:"floor"`
+	Id       int         `json:"slot"`
+	Type     VehicleType `json:"type"`
+	Occupied bool        `json:"occupied"`
+	Distance int         `json:"distance,omitempty"`
+	Charger  bool        `json:"charger,omitempty"`
+	HeldBy   string      `json:"heldBy,omitempty"`
+	Disabled bool        `json:"disabled,omitempty"`
+}
+
+type reservationState struct {
+	Id          string            `json:"id"`
+	VehicleType VehicleType       `json:"vehicleType"`
+	From        time.Time         `json:"from"`
+	To          time.Time         `json:"to"`
+	Status      ReservationStatus `json:"status"`
+	TicketId    string            `json:"ticketId,omitempty"`
+	NoShowFee   Fee               `json:"noShowFee"`
+	NoShowTime  time.Time         `json:"noShowTime,omitzero"`
+}
+
+type ticketState struct {
+	Id             string        `json:"id"`
+	Visit          uint64        `json:"visit,omitempty"`
+	Vehicle        Vehicle       `json:"vehicle"`
+	FloorId        int           `json:"floor"`
+	SlotId         int           `json:"slot"`
+	SlotCount      int           `json:"slotCount,omitempty"`
+	CheckinTime    int64         `json:"checkinTime"`
+	Status         TicketStatus  `json:"status"`
+	RateMultiplier float64       `json:"rateMultiplier,omitempty"`
+	EnergyKWh      float64       `json:"energyKWh,omitempty"`
+	Coupon         string        `json:"coupon,omitempty"`
+	BilledAs       VehicleType   `json:"billedAs"`
+	PaidTime       int64         `json:"paidTime,omitempty"`
+	CheckoutTime   int64         `json:"checkoutTime,omitempty"`
+	Fee            Fee           `json:"fee"`
+	Payments       []Transaction `json:"payments,omitempty"`
+}
+
+// lotSnapshot is the on disk format, bump snapshotVersion on incompatible changes
+type lotSnapshot struct {
+	Version int    `json:"version"`
+	LotId   string `json:"lotId"`
+	TakenAt int64  `json:"takenAt"`
+	// JournalSeq -> last journal event folded into this snapshot
+	JournalSeq uint64        `json:"journalSeq,omitempty"`
+	Slots      []slotState   `json:"slots"`
+	Tickets    []ticketState `json:"tickets"`
+	// DrainingFloors are removed once their last vehicle leaves
+	DrainingFloors []int              `json:"drainingFloors,omitempty"`
+	ReservationSeq uint64             `json:"reservationSeq,omitempty"`
+	Reservations   []reservationState `json:"reservations,omitempty"`
+}
+
+// Snapshot writes slots & tickets as JSON, the lot is frozen while the state is copied
+func (pl *ParkingLot) Snapshot(w io.Writer) error {
+	snap := pl.snapshot()
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(snap)
+}
+
+func (pl *ParkingLot) snapshot() lotSnapshot {
+	pl.lockAll()
+	defer pl.unlockAll()
+	pl.ticketLock.RLock()
+	defer pl.ticketLock.RUnlock()
+	return pl.snapshotLocked()
+}
+
+// snapshotLocked expects every type lock and the ticket lock to be held
+func (pl *ParkingLot) snapshotLocked() lotSnapshot {
+	journalSeq := pl.journalSeq
+	if pl.journal != nil {
+		journalSeq = max(journalSeq, pl.journal.LastSeq())
+	}
+	snap := lotSnapshot{
+		Version:    snapshotVersion,
+		LotId:      pl.id,
+		TakenAt:    pl.clock.Now().UnixNano(),
+		JournalSeq: journalSeq,
+		Slots:      make([]slotState, 0, len(pl.slotStore)),
+		Tickets:    make([]ticketState, 0, len(pl.ticketStore)),
+	}
+	for _, slot := range pl.slotStore {
+		// waitlist holds are not kept, waiters can not outlive the process
+		heldBy := slot.HeldBy
+		if heldBy != "" && pl.isWaitlistHold(slot) {
+			heldBy = ""
+		}
+		snap.Slots = append(snap.Slots, slotState{
+			FloorId:  slot.FloorId,
+			Id:       slot.Id,
+			Type:     slot.Type,
+			Occupied: slot.IsOccupied,
+			Distance: slot.Distance,
+			Charger:  slot.Charger,
+			HeldBy:   heldBy,
+			Disabled: slot.Disabled,
+		})
+	}
+	for floor := range pl.drainingFloors {
+		snap.DrainingFloors = append(snap.DrainingFloors, floor)
+	}
+	slices.Sort(snap.DrainingFloors)
+	slices.SortFunc(snap.Slots, func(a slotState, b slotState) int {
+		if a.FloorId != b.FloorId {
+			return a.FloorId - b.FloorId
+		}
+		return a.Id - b.Id
+	})
+
+	for _, ticket := range pl.ticketStore {
+		snap.Tickets = append(snap.Tickets, ticketState{
+			Id:             ticket.Id,
+			Visit:          ticket.Visit,
+			Vehicle:        *ticket.VehicleParked,
+			FloorId:        ticket.SlotDetails.FloorId,
+			SlotId:         ticket.SlotDetails.Id,
+			SlotCount:      len(ticket.occupiedSlots()),
+			CheckinTime:    ticket.CheckinTime,
+			Status:         ticket.Status,
+			RateMultiplier: ticket.RateMultiplier,
+			EnergyKWh:      ticket.EnergyKWh,
+			Coupon:         ticket.Coupon,
+			BilledAs:       ticket.BilledAs,
+			PaidTime:       ticket.PaidTime,
+			CheckoutTime:   ticket.CheckoutTime,
+			Fee:            ticket.Fee,
+			Payments:       ticket.Payments,
+		})
+	}
+	slices.SortFunc(snap.Tickets, func(a ticketState, b ticketState) int {
+		return strings.Compare(a.Id, b.Id)
+	})
+
+	pl.reservationLock.Lock()
+	defer pl.reservationLock.Unlock()
+	snap.ReservationSeq = pl.reservationSeq
+	for _, r := range pl.reservations {
+		snap.Reservations = append(snap.Reservations, reservationState{
+			Id:          r.Id,
+			VehicleType: r.VehicleType,
+			From:        r.From,
+			To:          r.To,
+			Status:      r.Status,
+			TicketId:    r.TicketId,
+			NoShowFee:   r.NoShowFee,
+			NoShowTime:  r.NoShowTime,
+		})
+	}
+	slices.SortFunc(snap.Reservations, func(a reservationState, b reservationState) int {
+		return strings.Compare(a.Id, b.Id)
+	})
+	return snap
+}
+
+// Restore replaces the whole lot state with a snapshot written by Snapshot,
+// the availability index is rebuilt from the slot states
+func (pl *ParkingLot) Restore(r io.Reader) error {
+	var snap lotSnapshot
+	if err := json.NewDecoder(r).Decode(&snap); err != nil {
+		return fmt.Errorf("invalid snapshot: %w", err)
+	}
+	return pl.restore(snap)
+}
+
+func (pl *ParkingLot) restore(snap lotSnapshot) error {
+	switch snap.Version {
+	case 1:
+		for i := range snap.Tickets {
+			snap.Tickets[i].BilledAs = snap.Tickets[i].Vehicle.Type
+		}
+	case 2, snapshotVersion:
+	default:
+		return fmt.Errorf("%w: %d", ErrSnapshotVersion, snap.Version)
+	}
+	// ticket ids carry the lot id, they would not resolve in another lot
+	if snap.LotId != "" && snap.LotId != pl.id {
+		return fmt.Errorf("invalid snapshot: taken from lot %s, restoring into %s", snap.LotId, pl.id)
+	}
+
+	slots := make([]*Slot, 0, len(snap.Slots))
+	for _, state := range snap.Slots {
+		slots = append(slots, &Slot{
+			Id:         state.Id,
+			FloorId:    state.FloorId,
+			Type:       state.Type,
+			IsOccupied: state.Occupied,
+			Distance:   state.Distance,
+			Charger:    state.Charger,
+			HeldBy:     state.HeldBy,
+			Disabled:   state.Disabled,
+		})
+	}
+	slotStore, availableSlots, slotCount := indexSlots(slots)
+	if len(slotStore) != len(slots) {
+		return fmt.Errorf("invalid snapshot: duplicate slots")
+	}
+
+	ticketStore := make(map[string]*ParkingTicket, len(snap.Tickets))
+	closed := make([]*ParkingTicket, 0)
+	activeOn := make(map[*Slot]string)
+	for _, state := range snap.Tickets {
+		taken, err := slotRun(slotStore, state.FloorId, state.SlotId, max(state.SlotCount, 1))
+		if err != nil && state.Status == TicketClosed {
+			// the floor was removed after the checkout
+			taken, err = removedSlotRun(state), nil
+		}
+		if err != nil {
+			return fmt.Errorf("invalid snapshot: ticket %s: %w", state.Id, err)
+		}
+		vehicle := state.Vehicle
+		ticket := &ParkingTicket{
+			Id:             state.Id,
+			Visit:          state.Visit,
+			VehicleParked:  &vehicle,
+			CheckinTime:    state.CheckinTime,
+			SlotDetails:    taken[0],
+			Slots:          taken,
+			Status:         state.Status,
+			RateMultiplier: state.RateMultiplier,
+			EnergyKWh:      state.EnergyKWh,
+			Coupon:         state.Coupon,
+			BilledAs:       state.BilledAs,
+			PaidTime:       state.PaidTime,
+			CheckoutTime:   state.CheckoutTime,
+			Fee:            state.Fee,
+			Payments:       state.Payments,
+		}
+		if _, exists := ticketStore[state.Id]; exists {
+			return fmt.Errorf("invalid snapshot: duplicate ticket %s", state.Id)
+		}
+		ticketStore[state.Id] = ticket
+		if state.Status == TicketClosed {
+			closed = append(closed, ticket)
+			continue
+		}
+		for _, slot := range taken {
+			if other, shared := activeOn[slot]; shared {
+				return fmt.Errorf("invalid snapshot: tickets %s and %s share slot %d-%d", other, state.Id, slot.FloorId, slot.Id)
+			}
+			activeOn[slot] = state.Id
+		}
+	}
+	parkedVehicles, err := vehicleIndexOf(ticketStore)
+	if err != nil {
+		return fmt.Errorf("invalid snapshot: %w", err)
+	}
+	// every occupied slot needs its active ticket and the other way round
+	for _, slot := range slotStore {
+		if _, active := activeOn[slot]; active != slot.IsOccupied {
+			return fmt.Errorf("invalid snapshot: slot %d-%d occupancy does not match its tickets", slot.FloorId, slot.Id)
+		}
+	}
+
+	drainingFloors := make(map[int]bool, len(snap.DrainingFloors))
+	for _, floor := range snap.DrainingFloors {
+		drainingFloors[floor] = true
+	}
+
+	reservations := make(map[string]*Reservation, len(snap.Reservations))
+	for _, state := range snap.Reservations {
+		reservations[state.Id] = &Reservation{
+			Id:          state.Id,
+			VehicleType: state.VehicleType,
+			From:        state.From,
+			To:          state.To,
+			Status:      state.Status,
+			TicketId:    state.TicketId,
+			NoShowFee:   state.NoShowFee,
+			NoShowTime:  state.NoShowTime,
+		}
+	}
+	for _, slot := range slotStore {
+		if slot.HeldBy == "" {
+			continue
+		}
+		r, exists := reservations[slot.HeldBy]
+		if !exists || r.Status != ReservationHeld || slot.IsOccupied {
+			return fmt.Errorf("invalid snapshot: slot %d-%d held by %q", slot.FloorId, slot.Id, slot.HeldBy)
+		}
+		r.Slot = slot
+	}
+	for _, r := range reservations {
+		if r.Status == ReservationHeld && r.Slot == nil {
+			return fmt.Errorf("invalid snapshot: reservation %s is held without a slot", r.Id)
+		}
+	}
+
+	pl.lockAll()
+	defer pl.unlockAll()
+	pl.ticketLock.Lock()
+	defer pl.ticketLock.Unlock()
+
+	pl.slotStore = slotStore
+	pl.availableSlots = availableSlots
+	pl.slotCount = slotCount
+	pl.ticketStore = ticketStore
+	pl.historyLock.Lock()
+	pl.history = historyOf(closed)
+	pl.historyLock.Unlock()
+	pl.vehicleLock.Lock()
+	pl.parkedVehicles = parkedVehicles
+	pl.vehicleLock.Unlock()
+	pl.drainingFloors = drainingFloors
+	pl.clearWaitlist()
+	pl.journalSeq = snap.JournalSeq
+	pl.reservationLock.Lock()
+	pl.reservations = reservations
+	pl.reservationSeq = snap.ReservationSeq
+	pl.reservationLock.Unlock()
+	pl.ticketSeq.Store(0)
+	for _, ticket := range ticketStore {
+		pl.observeVisit(ticket.Visit)
+	}
+	return nil
+}
+
+// removedSlotRun stands for the slots of a closed ticket whose floor is gone
+func removedSlotRun(state ticketState) []*Slot {
+	slots := make([]*Slot, 0, max(state.SlotCount, 1))
+	for i := range max(state.SlotCount, 1) {
+		slots = append(slots, &Slot{Id: state.SlotId + i, FloorId: state.FloorId, Type: state.Vehicle.Type.SlotType(), Disabled: true})
+	}
+	return slots
+}
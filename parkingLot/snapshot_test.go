@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	clock := NewFakeClock(time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC))
+	layout := Layout{Floors: []FloorLayout{UniformFloor(Car, 3)}}
+	lot, err := NewParkingLot(layout, &NormalPricing{Clock: clock}, WithClock(clock))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tickets []ParkingTicket
+	for _, registration := range []string{"KA-01-0001", "KA-01-0002"} {
+		ticket, err := lot.CheckIn(Vehicle{RegistrationNumber: registration, Type: Car})
+		if err != nil {
+			t.Fatal(err)
+		}
+		tickets = append(tickets, ticket)
+	}
+	clock.Advance(time.Hour)
+	if _, err := lot.Unpark(tickets[0].Id); err != nil {
+		t.Fatal(err)
+	}
+
+	var snap bytes.Buffer
+	if err := lot.Snapshot(&snap); err != nil {
+		t.Fatal(err)
+	}
+	restored, err := NewParkingLot(layout, &NormalPricing{Clock: clock}, WithClock(clock))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := restored.Restore(bytes.NewReader(snap.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := restored.FreeSlots(Car), lot.FreeSlots(Car); !sameFloors(got, want) {
+		t.Fatalf("restored free slots %v, want %v", got, want)
+	}
+	if _, err := restored.Unpark(tickets[0].Id); !errors.Is(err, ErrTicketClosed) {
+		t.Fatalf("Unpark of the closed ticket err = %v, want ErrTicketClosed", err)
+	}
+	// the active ticket keeps its check in time
+	clock.Advance(time.Hour)
+	receipt, err := restored.Unpark(tickets[1].Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if receipt.CheckinTime != tickets[1].CheckinTime || receipt.Fee.Total != 2*Car.BasePrice() {
+		t.Fatalf("receipt %+v", receipt)
+	}
+}
+
+func TestRestoreRejectsBadSnapshots(t *testing.T) {
+	lot, err := NewParkingLot(Layout{Floors: []FloorLayout{UniformFloor(Car, 2)}}, &NormalPricing{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := lot.CheckIn(Vehicle{RegistrationNumber: "KA-01-0001", Type: Car}); err != nil {
+		t.Fatal(err)
+	}
+	var snap bytes.Buffer
+	if err := lot.Snapshot(&snap); err != nil {
+		t.Fatal(err)
+	}
+
+	version := regexp.MustCompile(`"version": \d+`).ReplaceAllString(snap.String(), `"version": 99`)
+	if err := lot.Restore(strings.NewReader(version)); !errors.Is(err, ErrSnapshotVersion) {
+		t.Errorf("Restore of version 99 err = %v, want ErrSnapshotVersion", err)
+	}
+	// the free slot marked occupied has no ticket
+	occupied := strings.Replace(snap.String(), `"occupied": false`, `"occupied": true`, 1)
+	if err := lot.Restore(strings.NewReader(occupied)); err == nil {
+		t.Errorf("Restore accepted an occupied slot without ticket")
+	}
+	if err := lot.Restore(strings.NewReader("{")); err == nil {
+		t.Errorf("Restore accepted truncated JSON")
+	}
+	// a failed restore leaves the lot as it was
+	if got := lot.FreeSlotCount(Car); got != 1 {
+		t.Errorf("FreeSlotCount(Car) = %d after failed restores, want 1", got)
+	}
+}
+
+func newSnapshotLot(t *testing.T, clock *FakeClock) *ParkingLot {
+	t.Helper()
+	lot, err := NewParkingLot(Layout{Floors: []FloorLayout{UniformFloor(Car, 3)}}, &NormalPricing{Clock: clock},
+		WithClock(clock), WithPaymentProcessor(NewFakeGateway()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return lot
+}
+
+func TestSnapshotKeepsPaidTicketsAndReservations(t *testing.T) {
+	clock := NewFakeClock(time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC))
+	lot := newSnapshotLot(t, clock)
+	ticket, err := lot.CheckIn(Vehicle{RegistrationNumber: "KA-01-0001", Type: Car})
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(time.Hour)
+	if _, err := lot.Pay(ticket.Id, Payment{Method: PayCash, Tendered: 100}); err != nil {
+		t.Fatal(err)
+	}
+	reservation, err := lot.Reserve(Car, clock.Now().Add(time.Hour), clock.Now().Add(3*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := lot.Snapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+	restored := newSnapshotLot(t, clock)
+	if err := restored.Restore(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := restored.GetTicket(ticket.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != TicketPaid || got.PaidTime == 0 || len(got.Payments) != 1 {
+		t.Fatalf("restored ticket %+v", got)
+	}
+	if _, err := restored.GetReservation(reservation.Id); err != nil {
+		t.Fatal(err)
+	}
+	if free := restored.FreeSlotCount(Car); free != 2 {
+		t.Fatalf("%d free Car slots after restore, want 2", free)
+	}
+}
+
+func TestRestoreVersions(t *testing.T) {
+	clock := NewFakeClock(time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC))
+	lot := newSnapshotLot(t, clock)
+	if _, err := lot.CheckIn(Vehicle{RegistrationNumber: "KA-01-0001", Type: Car}); err != nil {
+		t.Fatal(err)
+	}
+	snap := lot.snapshot()
+
+	// version 2 snapshots predate reservations, draining floors & payments, they restore as is
+	snap.Version = 2
+	if err := newSnapshotLot(t, clock).restore(snap); err != nil {
+		t.Fatalf("restore of a version 2 snapshot: %v", err)
+	}
+
+	snap.Version = snapshotVersion + 1
+	data, err := json.Marshal(snap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := newSnapshotLot(t, clock).Restore(bytes.NewReader(data)); !errors.Is(err, ErrSnapshotVersion) {
+		t.Fatalf("restore of version %d err = %v, want ErrSnapshotVersion", snap.Version, err)
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"slices"
+)
+
+// RateLocker is implemented by pricings that fix the rate when the vehicle enters,
+// CheckIn stores the returned multiplier on the ticket
+type RateLocker interface {
+	LockRate(vtype VehicleType, occupancy Occupancy) float64
+}
+
+// SurgeTier applies Multiplier once the occupied ratio of a vehicle type reaches MinOccupancy (0..1)
+type SurgeTier struct {
+	MinOccupancy float64
+	Multiplier   float64
+}
+
+// SurgePricing raises the fee of Base as the lot fills up, the multiplier is locked at check in
+// so the driver pays what was quoted on entry
+type SurgePricing struct {
+	Base  Pricing
+	Tiers []SurgeTier
+}
+
+func (ps *SurgePricing) LockRate(vtype VehicleType, occupancy Occupancy) float64 {
+	if occupancy.Total() == 0 {
+		return 1
+	}
+	ratio := float64(occupancy.Occupied) / float64(occupancy.Total())
+
+	tiers := slices.Clone(ps.Tiers)
+	slices.SortFunc(tiers, func(a SurgeTier, b SurgeTier) int {
+		switch {
+		case a.MinOccupancy < b.MinOccupancy:
+			return -1
+		case a.MinOccupancy > b.MinOccupancy:
+			return 1
+		}
+		return 0
+	})
+
+	multiplier := 1.0
+	for _, tier := range tiers {
+		if ratio >= tier.MinOccupancy {
+			multiplier = tier.Multiplier
+		}
+	}
+	return multiplier
+}
+
+func (ps *SurgePricing) CalculatePrice(ticket ParkingTicket) Fee {
+	fee := ps.Base.CalculatePrice(ticket)
+	multiplier := ticket.RateMultiplier
+	if multiplier == 0 || multiplier == 1 {
+		return fee
+	}
+	surcharge := int(math.Round(float64(fee.Total) * (multiplier - 1)))
+	fee.add(fmt.Sprintf("Surge x%.2f", multiplier), surcharge)
+	return fee
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestSurgeLockRateTiers(t *testing.T) {
+	// tiers given out of order, the highest one reached wins
+	pricing := &SurgePricing{Tiers: []SurgeTier{{MinOccupancy: 0.9, Multiplier: 2}, {MinOccupancy: 0.5, Multiplier: 1.5}}}
+	tests := []struct {
+		occupancy Occupancy
+		want      float64
+	}{
+		{Occupancy{}, 1},
+		{Occupancy{Free: 10}, 1},
+		{Occupancy{Free: 6, Occupied: 4}, 1},
+		{Occupancy{Free: 5, Occupied: 5}, 1.5},
+		{Occupancy{Free: 2, Occupied: 8}, 1.5},
+		{Occupancy{Free: 1, Occupied: 9}, 2},
+		{Occupancy{Occupied: 10}, 2},
+	}
+	for _, tt := range tests {
+		if got := pricing.LockRate(Car, tt.occupancy); got != tt.want {
+			t.Errorf("LockRate(%+v) = %v, want %v", tt.occupancy, got, tt.want)
+		}
+	}
+}
+
+func TestSurgeSurchargeLine(t *testing.T) {
+	pricing := &SurgePricing{Base: &MeteredPricing{Policy: BillingPolicy{Unit: time.Hour}}}
+	checkin := time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC)
+	ticket := ParkingTicket{
+		VehicleParked: &Vehicle{RegistrationNumber: "KA-01-0001", Type: Car},
+		BilledAs:      Car,
+		CheckinTime:   checkin.UnixNano(),
+		CheckoutTime:  checkin.Add(3 * time.Hour).UnixNano(),
+	}
+	for multiplier, want := range map[float64]int{0: 60, 1: 60, 1.25: 75, 2: 120} {
+		ticket.RateMultiplier = multiplier
+		fee := pricing.CalculatePrice(ticket)
+		if fee.Total != want {
+			t.Errorf("x%v: fee %d (%+v), want %d", multiplier, fee.Total, fee.Lines, want)
+		}
+		if surged := len(fee.Lines) > 1; surged != (want != 60) {
+			t.Errorf("x%v: lines %+v", multiplier, fee.Lines)
+		}
+	}
+}
+
+func TestSurgeRateLockedAtCheckIn(t *testing.T) {
+	clock := NewFakeClock(time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC))
+	pricing := &SurgePricing{
+		Base:  &NormalPricing{Clock: clock},
+		Tiers: []SurgeTier{{MinOccupancy: 0.75, Multiplier: 2}, {MinOccupancy: 0.5, Multiplier: 1.5}},
+	}
+	lot, err := NewParkingLot(Layout{Floors: []FloorLayout{UniformFloor(Car, 4)}}, pricing, WithClock(clock))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// occupancy seen on entry : 0/4, 1/4, 2/4, 3/4
+	wantMultiplier := []float64{1, 1, 1.5, 2}
+	tickets := make([]ParkingTicket, len(wantMultiplier))
+	for i, want := range wantMultiplier {
+		tickets[i], err = lot.CheckIn(Vehicle{RegistrationNumber: fmt.Sprintf("KA-01-%04d", i), Type: Car})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tickets[i].RateMultiplier != want {
+			t.Fatalf("vehicle %d multiplier %v, want %v", i, tickets[i].RateMultiplier, want)
+		}
+	}
+
+	// the lot empties before the surged vehicles leave, their rate stays the one quoted on entry
+	clock.Advance(2 * time.Hour)
+	for _, ticket := range tickets[:2] {
+		if _, err := lot.Unpark(ticket.Id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i, want := range []int{2 * 20 * 3 / 2, 2 * 20 * 2} {
+		receipt, err := lot.Unpark(tickets[2+i].Id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if receipt.Fee.Total != want {
+			t.Fatalf("surged vehicle %d fee %d (%+v), want %d", i, receipt.Fee.Total, receipt.Fee.Lines, want)
+		}
+	}
+}
+
+func TestSurgeWithoutTiersIsBase(t *testing.T) {
+	pricing := &SurgePricing{Base: &NormalPricing{}}
+	if got := pricing.LockRate(Car, Occupancy{Free: 0, Occupied: 10}); got != 1 {
+		t.Fatalf("multiplier %v on a full lot without tiers, want 1", got)
+	}
+	if got := pricing.LockRate(Car, Occupancy{}); got != 1 {
+		t.Fatalf("multiplier %v on an empty pool, want 1", got)
+	}
+}
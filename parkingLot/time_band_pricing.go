@@ -0,0 +1,150 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"time"
+)
+
+type DayKind int
+
+const (
+	Weekday DayKind = iota
+	Weekend
+	Holiday
+)
+
+func (d DayKind) ToString() string {
+	switch d {
+	case Weekday:
+		return "Weekday"
+	case Weekend:
+		return "Weekend"
+	case Holiday:
+		return "Holiday"
+	default:
+		return ""
+	}
+}
+
+// TimeBand is a rate card valid on some kinds of day between FromHour and ToHour.
+// FromHour > ToHour wraps over midnight (22 -> 6), FromHour == ToHour covers the whole day
+type TimeBand struct {
+	Name     string
+	Days     []DayKind
+	FromHour int
+	ToHour   int
+	// Rates -> price per hour
+	Rates map[VehicleType]int
+}
+
+func (b *TimeBand) covers(kind DayKind, hour int) bool {
+	if !slices.Contains(b.Days, kind) {
+		return false
+	}
+	switch {
+	case b.FromHour == b.ToHour:
+		return true
+	case b.FromHour < b.ToHour:
+		return hour >= b.FromHour && hour < b.ToHour
+	default:
+		return hour >= b.FromHour || hour < b.ToHour
+	}
+}
+
+// TimeBandPricing bills every part of the stay at the rate of the band it falls in,
+// a stay crossing band boundaries is split into segments billed separately
+type TimeBandPricing struct {
+	// Bands are matched in order, the first one covering an hour wins
+	Bands []TimeBand
+	// Holidays are matched by their calendar date, whatever their own location
+	Holidays []time.Time
+	// Location defaults to time.Local
+	Location *time.Location
+	// Clock prices active tickets, nil means SystemClock
+	Clock Clock
+}
+
+type bandSegment struct {
+	band  *TimeBand
+	kind  DayKind
+	start time.Time
+	end   time.Time
+}
+
+func (ps *TimeBandPricing) location() *time.Location {
+	if ps.Location == nil {
+		return time.Local
+	}
+	return ps.Location
+}
+
+func (ps *TimeBandPricing) dayKind(t time.Time) DayKind {
+	y, m, d := t.Date()
+	for _, h := range ps.Holidays {
+		hy, hm, hd := h.Date()
+		if y == hy && m == hm && d == hd {
+			return Holiday
+		}
+	}
+	if t.Weekday() == time.Saturday || t.Weekday() == time.Sunday {
+		return Weekend
+	}
+	return Weekday
+}
+
+// band returns nil when no band covers t, that hour is billed at BasePrice
+func (ps *TimeBandPricing) band(t time.Time, kind DayKind) *TimeBand {
+	for i := range ps.Bands {
+		if ps.Bands[i].covers(kind, t.Hour()) {
+			return &ps.Bands[i]
+		}
+	}
+	return nil
+}
+
+// segments splits [start, end) on hour boundaries and merges neighbours falling in the same band
+func (ps *TimeBandPricing) segments(start time.Time, end time.Time) []bandSegment {
+	var segments []bandSegment
+	for t := start; t.Before(end); {
+		y, m, d := t.Date()
+		next := time.Date(y, m, d, t.Hour()+1, 0, 0, 0, t.Location())
+		if next.After(end) {
+			next = end
+		}
+		kind := ps.dayKind(t)
+		band := ps.band(t, kind)
+
+		if n := len(segments); n > 0 && segments[n-1].band == band && segments[n-1].kind == kind {
+			segments[n-1].end = next
+		} else {
+			segments = append(segments, bandSegment{band: band, kind: kind, start: t, end: next})
+		}
+		t = next
+	}
+	return segments
+}
+
+func (ps *TimeBandPricing) CalculatePrice(ticket ParkingTicket) Fee {
+	loc := ps.location()
+	vtype := ticket.BilledAs
+	start := time.Unix(0, ticket.CheckinTime).In(loc)
+	end := checkoutTime(ticket, ps.Clock).In(loc)
+
+	var fee Fee
+	for _, seg := range ps.segments(start, end) {
+		name, rate := "Standard", vtype.BasePrice()
+		if seg.band != nil {
+			name = seg.band.Name
+			if r, exists := seg.band.Rates[vtype]; exists {
+				rate = r
+			}
+		}
+		dur := seg.end.Sub(seg.start)
+		// prorated, any started rupee is billed
+		amount := int((int64(rate)*int64(dur) + int64(time.Hour) - 1) / int64(time.Hour))
+		fee.add(fmt.Sprintf("%s %s %s-%s: %v @ %d/h", name, seg.kind.ToString(),
+			seg.start.Format("Mon 15:04"), seg.end.Format("Mon 15:04"), dur, rate), amount)
+	}
+	return fee
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTimeBandCovers(t *testing.T) {
+	day := TimeBand{Days: []DayKind{Weekday}, FromHour: 8, ToHour: 18}
+	night := TimeBand{Days: []DayKind{Weekday, Weekend}, FromHour: 22, ToHour: 6}
+	allDay := TimeBand{Days: []DayKind{Holiday}, FromHour: 0, ToHour: 0}
+	tests := []struct {
+		name string
+		band TimeBand
+		kind DayKind
+		hour int
+		want bool
+	}{
+		{"day start", day, Weekday, 8, true},
+		{"day end excluded", day, Weekday, 18, false},
+		{"day on a weekend", day, Weekend, 10, false},
+		{"night before midnight", night, Weekend, 23, true},
+		{"night after midnight", night, Weekday, 5, true},
+		{"night end excluded", night, Weekday, 6, false},
+		{"night at noon", night, Weekday, 12, false},
+		{"whole day", allDay, Holiday, 13, true},
+		{"whole day other kind", allDay, Weekday, 13, false},
+	}
+	for _, tt := range tests {
+		if got := tt.band.covers(tt.kind, tt.hour); got != tt.want {
+			t.Errorf("%s: covers(%s, %d) = %v, want %v", tt.name, tt.kind.ToString(), tt.hour, got, tt.want)
+		}
+	}
+}
+
+func TestTimeBandPricingWeekendProrated(t *testing.T) {
+	pricing := &TimeBandPricing{
+		Bands: []TimeBand{
+			{Name: "Weekend", Days: []DayKind{Weekend}, FromHour: 0, ToHour: 0, Rates: map[VehicleType]int{Car: 30}},
+			// never reached, the first band covering an hour wins
+			{Name: "Saturday morning", Days: []DayKind{Weekend}, FromHour: 8, ToHour: 12, Rates: map[VehicleType]int{Car: 1}},
+		},
+		Location: time.UTC,
+	}
+	// Saturday 10:00 -> 11:20
+	checkin := time.Date(2024, 3, 9, 10, 0, 0, 0, time.UTC)
+	ticket := ParkingTicket{
+		VehicleParked: &Vehicle{RegistrationNumber: "KA-01-0001", Type: Car},
+		BilledAs:      Car,
+		CheckinTime:   checkin.UnixNano(),
+		CheckoutTime:  checkin.Add(80 * time.Minute).UnixNano(),
+	}
+	fee := pricing.CalculatePrice(ticket)
+	if len(fee.Lines) != 1 || fee.Total != 40 {
+		t.Fatalf("fee %d (%+v), want one Weekend line of 40", fee.Total, fee.Lines)
+	}
+
+	// the band has no Truck rate : BasePrice, a started rupee is billed
+	ticket.VehicleParked = &Vehicle{RegistrationNumber: "KA-01-0002", Type: Truck}
+	ticket.BilledAs = Truck
+	ticket.CheckoutTime = checkin.Add(time.Minute + time.Second).UnixNano()
+	if fee := pricing.CalculatePrice(ticket); fee.Total != 1 {
+		t.Fatalf("fee %d (%+v), want 1", fee.Total, fee.Lines)
+	}
+}
+
+func newBandPricing(clock Clock, loc *time.Location) *TimeBandPricing {
+	return &TimeBandPricing{
+		Bands: []TimeBand{
+			{Name: "Peak", Days: []DayKind{Weekday}, FromHour: 8, ToHour: 18, Rates: map[VehicleType]int{Car: 40}},
+			{Name: "Night", Days: []DayKind{Weekday, Weekend, Holiday}, FromHour: 22, ToHour: 6, Rates: map[VehicleType]int{Car: 10}},
+			{Name: "Holiday", Days: []DayKind{Holiday}, FromHour: 0, ToHour: 0, Rates: map[VehicleType]int{Car: 5}},
+		},
+		Holidays: []time.Time{time.Date(2024, 12, 25, 0, 0, 0, 0, time.UTC)},
+		Location: loc,
+		Clock:    clock,
+	}
+}
+
+// activeCar is checked in at checkin, CheckoutTime 0 -> priced at the clock's now
+func activeCar(checkin time.Time) ParkingTicket {
+	return ParkingTicket{BilledAs: Car, CheckinTime: checkin.UnixNano()}
+}
+
+func TestTimeBandPricingSplitsStay(t *testing.T) {
+	// Monday 16:00 -> 19:30 : 2h Peak, then 1h30 outside any band at BasePrice
+	start := time.Date(2024, 3, 4, 16, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+	pricing := newBandPricing(clock, time.UTC)
+	ticket := activeCar(start)
+	clock.Advance(3*time.Hour + 30*time.Minute)
+
+	fee := pricing.CalculatePrice(ticket)
+	if len(fee.Lines) != 2 {
+		t.Fatalf("lines %+v, want 2 segments", fee.Lines)
+	}
+	if want := 2*40 + 30; fee.Total != want {
+		t.Fatalf("total %d, want %d", fee.Total, want)
+	}
+}
+
+func TestTimeBandPricingWrapsMidnight(t *testing.T) {
+	// Friday 23:00 -> Saturday 07:00 : Night covers 23-06 on both days, then 1h Standard
+	start := time.Date(2024, 3, 8, 23, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+	pricing := newBandPricing(clock, time.UTC)
+	ticket := activeCar(start)
+	clock.Advance(8 * time.Hour)
+
+	fee := pricing.CalculatePrice(ticket)
+	if want := 7*10 + 20; fee.Total != want {
+		t.Fatalf("total %d (%+v), want %d", fee.Total, fee.Lines, want)
+	}
+}
+
+func TestTimeBandPricingHolidayByCalendarDate(t *testing.T) {
+	// the holiday is given at UTC midnight, the lot is west of UTC : Dec 25 is still the holiday there
+	loc := time.FixedZone("UTC-5", -5*60*60)
+	start := time.Date(2024, 12, 25, 10, 0, 0, 0, loc)
+	clock := NewFakeClock(start)
+	pricing := newBandPricing(clock, loc)
+	ticket := activeCar(start)
+	clock.Advance(2 * time.Hour)
+
+	fee := pricing.CalculatePrice(ticket)
+	if want := 2 * 5; fee.Total != want {
+		t.Fatalf("total %d (%+v), want %d at the holiday rate", fee.Total, fee.Lines, want)
+	}
+}
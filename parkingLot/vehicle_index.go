@@ -0,0 +1,104 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	ErrVehicleAlreadyParked = errors.New("vehicle already parked")
+	ErrVehicleNotFound      = errors.New("vehicle not parked")
+)
+
+// VehicleLocation tells where a parked vehicle is
+type VehicleLocation struct {
+	LotId    string
+	TicketId string
+	FloorId  int
+	SlotId   int
+	// SlotIds -> every slot on FloorId the vehicle took
+	SlotIds     []int
+	CheckinTime time.Time
+}
+
+// indexVehicle records the vehicle under the ticket id returned by ticketID, a vehicle can only be inside once.
+// ticketID is only called once the vehicle is known not to be inside, so a rejected check in does not use up an id.
+// Vehicles without registration number are not indexed
+func (pl *ParkingLot) indexVehicle(vehicle Vehicle, ticketID func() string) (string, error) {
+	if vehicle.RegistrationNumber == "" {
+		return ticketID(), nil
+	}
+	pl.vehicleLock.Lock()
+	defer pl.vehicleLock.Unlock()
+	if current, parked := pl.parkedVehicles[vehicle.RegistrationNumber]; parked {
+		return "", fmt.Errorf("%w: %s (ticket %s)", ErrVehicleAlreadyParked, vehicle.RegistrationNumber, current)
+	}
+	id := ticketID()
+	pl.parkedVehicles[vehicle.RegistrationNumber] = id
+	return id, nil
+}
+
+// unindexVehicle drops the vehicle of a ticket leaving the lot
+func (pl *ParkingLot) unindexVehicle(ticket *ParkingTicket) {
+	pl.vehicleLock.Lock()
+	defer pl.vehicleLock.Unlock()
+	if pl.parkedVehicles[ticket.VehicleParked.RegistrationNumber] == ticket.Id {
+		delete(pl.parkedVehicles, ticket.VehicleParked.RegistrationNumber)
+	}
+}
+
+// vehicleIndexOf rebuilds the index from the active tickets of a ticket store
+func vehicleIndexOf(ticketStore map[string]*ParkingTicket) (map[string]string, error) {
+	parked := make(map[string]string)
+	for _, ticket := range ticketStore {
+		registration := ticket.VehicleParked.RegistrationNumber
+		if ticket.Status == TicketClosed || registration == "" {
+			continue
+		}
+		if other, exists := parked[registration]; exists {
+			return nil, fmt.Errorf("%w: %s holds tickets %s and %s", ErrVehicleAlreadyParked, registration, other, ticket.Id)
+		}
+		parked[registration] = ticket.Id
+	}
+	return parked, nil
+}
+
+// FindVehicle returns where the vehicle with registrationNumber is parked
+func (pl *ParkingLot) FindVehicle(registrationNumber string) (VehicleLocation, error) {
+	pl.vehicleLock.Lock()
+	ticketID, parked := pl.parkedVehicles[registrationNumber]
+	pl.vehicleLock.Unlock()
+	if !parked {
+		return VehicleLocation{}, fmt.Errorf("%w: %s", ErrVehicleNotFound, registrationNumber)
+	}
+
+	ticket, err := pl.GetTicket(ticketID)
+	// the vehicle may have left meanwhile, the ticket has to be its own
+	if err != nil || ticket.Status == TicketClosed || ticket.VehicleParked.RegistrationNumber != registrationNumber {
+		return VehicleLocation{}, fmt.Errorf("%w: %s", ErrVehicleNotFound, registrationNumber)
+	}
+	receipt := ticket.receipt()
+	return VehicleLocation{
+		LotId:       pl.id,
+		TicketId:    ticket.Id,
+		FloorId:     receipt.FloorId,
+		SlotId:      receipt.SlotId,
+		SlotIds:     receipt.SlotIds,
+		CheckinTime: time.Unix(0, ticket.CheckinTime),
+	}, nil
+}
+
+// FindVehicle looks for the vehicle in every lot
+func (r *LotRegistry) FindVehicle(registrationNumber string) (VehicleLocation, error) {
+	for _, id := range r.IDs() {
+		pl, err := r.Get(id)
+		if err != nil {
+			continue
+		}
+		if location, err := pl.FindVehicle(registrationNumber); err == nil {
+			return location, nil
+		}
+	}
+	return VehicleLocation{}, fmt.Errorf("%w: %s", ErrVehicleNotFound, registrationNumber)
+}
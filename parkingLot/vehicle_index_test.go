@@ -0,0 +1,98 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestVehicleAlreadyParked(t *testing.T) {
+	clock := NewFakeClock(time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC))
+	lot, err := NewParkingLot(DefaultLayout(1, 6), &NormalPricing{Clock: clock}, WithClock(clock))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ticket, err := lot.CheckIn(Vehicle{RegistrationNumber: "KA-01-0001", Type: Car})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the registration number is the key, not the type
+	for _, vtype := range []VehicleType{Car, Bike} {
+		if _, err := lot.CheckIn(Vehicle{RegistrationNumber: "KA-01-0001", Type: vtype}); !errors.Is(err, ErrVehicleAlreadyParked) {
+			t.Fatalf("second %s check in err = %v, want ErrVehicleAlreadyParked", vtype.ToString(), err)
+		}
+	}
+	if free := lot.FreeSlotCount(Car); free != 1 {
+		t.Fatalf("%d free Car slots after the rejected check in, want 1", free)
+	}
+	clock.Advance(time.Hour)
+	if _, err := lot.Unpark(ticket.Id); err != nil {
+		t.Fatal(err)
+	}
+	// back in once it left
+	if _, err := lot.CheckIn(Vehicle{RegistrationNumber: "KA-01-0001", Type: Car}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFindVehicle(t *testing.T) {
+	registry := NewLotRegistry()
+	lots := make(map[string]*ParkingLot)
+	for _, id := range []string{"PR123", "PR456"} {
+		lot, err := NewParkingLot(DefaultLayout(2, 6), &NormalPricing{}, WithID(id))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := registry.Register(lot); err != nil {
+			t.Fatal(err)
+		}
+		lots[id] = lot
+	}
+	lots["PR456"].CheckIn(Vehicle{RegistrationNumber: "KA-01-0001", Type: Truck})
+	ticket, err := lots["PR456"].CheckIn(Vehicle{RegistrationNumber: "KA-01-0002", Type: Truck})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	location, err := registry.FindVehicle("KA-01-0002")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := VehicleLocation{LotId: "PR456", TicketId: ticket.Id, FloorId: 1, SlotId: 1}
+	if location.LotId != want.LotId || location.TicketId != want.TicketId || location.FloorId != want.FloorId ||
+		location.SlotId != want.SlotId || !location.CheckinTime.Equal(time.Unix(0, ticket.CheckinTime)) {
+		t.Fatalf("location %+v, want %+v", location, want)
+	}
+	if _, err := lots["PR123"].FindVehicle("KA-01-0002"); !errors.Is(err, ErrVehicleNotFound) {
+		t.Fatalf("FindVehicle in the other lot err = %v, want ErrVehicleNotFound", err)
+	}
+
+	if _, err := registry.Unpark(ticket.Id); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := registry.FindVehicle("KA-01-0002"); !errors.Is(err, ErrVehicleNotFound) {
+		t.Fatalf("FindVehicle after unpark err = %v, want ErrVehicleNotFound", err)
+	}
+}
+
+// a stale index entry pointing at another vehicle's ticket does not locate the vehicle there
+func TestFindVehicleChecksTheTicket(t *testing.T) {
+	lot, err := NewParkingLot(DefaultLayout(1, 6), &NormalPricing{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ticket, err := lot.CheckIn(Vehicle{RegistrationNumber: "KA-01-0001", Type: Car})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lot.vehicleLock.Lock()
+	lot.parkedVehicles["KA-01-0009"] = ticket.Id
+	lot.vehicleLock.Unlock()
+
+	if _, err := lot.FindVehicle("KA-01-0009"); !errors.Is(err, ErrVehicleNotFound) {
+		t.Fatalf("FindVehicle through another vehicle's ticket err = %v, want ErrVehicleNotFound", err)
+	}
+	if location, err := lot.FindVehicle("KA-01-0001"); err != nil || location.TicketId != ticket.Id {
+		t.Fatalf("FindVehicle = %+v, %v", location, err)
+	}
+}
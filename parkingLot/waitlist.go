@@ -0,0 +1,306 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+)
+
+var (
+	ErrWaitlistNotFound = errors.New("waitlist entry not found")
+	ErrNoHold           = errors.New("no slot held for the waitlist entry")
+	ErrAlreadyWaiting   = errors.New("vehicle already on the waitlist")
+	ErrCanNotWait       = errors.New("vehicle type can not join the waitlist")
+)
+
+// DefaultWaitlistHoldTimeout is how long a freed slot is kept for a waiter
+const DefaultWaitlistHoldTimeout = 5 * time.Minute
+
+// WithWaitlistHoldTimeout sets how long a waiter has to check in on the slot held for it,
+// the slot then passes to the next waiter
+func WithWaitlistHoldTimeout(timeout time.Duration) Option {
+	return func(pl *ParkingLot) {
+		pl.holdTimeout = timeout
+	}
+}
+
+// WaitlistHold tells a waiter which slot is kept for it, and until when
+type WaitlistHold struct {
+	WaitId    string
+	FloorId   int
+	SlotId    int
+	ExpiresAt time.Time
+}
+
+// WaitlistEntry is handed out by JoinWaitlist
+type WaitlistEntry struct {
+	Id      string
+	Vehicle Vehicle
+	// Holds receives the hold once a slot is kept for the vehicle, it is closed when the entry
+	// leaves the waitlist : check in, LeaveWaitlist or hold timeout
+	Holds <-chan WaitlistHold
+}
+
+// WaitlistStatus is the state of an entry, Hold is nil while waiting
+type WaitlistStatus struct {
+	// Position -> waiters of the same vehicle type ahead, plus one
+	Position int
+	Hold     *WaitlistHold
+}
+
+type waiter struct {
+	id string
+	// seq is the joining order across vehicle types
+	seq     uint64
+	vehicle Vehicle
+	holds   chan WaitlistHold
+	// slot & hold are set while a slot is kept for the waiter, stop cancels the hold timeout
+	slot *Slot
+	hold WaitlistHold
+	stop func() bool
+}
+
+// JoinWaitlist queues the vehicle, FIFO per vehicle type. A slot freed later is held for the first waiter
+// it suits, a slot free right now is held straight away. Large vehicles can not wait
+func (pl *ParkingLot) JoinWaitlist(vehicle Vehicle) (WaitlistEntry, error) {
+	if vehicle.Type.SlotsRequired() > 1 {
+		return WaitlistEntry{}, fmt.Errorf("%w: %s", ErrCanNotWait, vehicle.Type.ToString())
+	}
+	pl.vehicleLock.Lock()
+	current, parked := pl.parkedVehicles[vehicle.RegistrationNumber]
+	pl.vehicleLock.Unlock()
+	if parked {
+		return WaitlistEntry{}, fmt.Errorf("%w: %s (ticket %s)", ErrVehicleAlreadyParked, vehicle.RegistrationNumber, current)
+	}
+
+	pools := pl.compatibility.slotTypes(vehicle.Type)
+	unlock := pl.lockPools(pools)
+	defer unlock()
+
+	pl.waitlistLock.Lock()
+	for _, w := range pl.waiters {
+		if w.vehicle.RegistrationNumber == vehicle.RegistrationNumber {
+			pl.waitlistLock.Unlock()
+			return WaitlistEntry{}, fmt.Errorf("%w: %s (%s)", ErrAlreadyWaiting, vehicle.RegistrationNumber, w.id)
+		}
+	}
+	pl.waitlistLock.Unlock()
+
+	// nobody waits while a suitable slot is free, take it right away
+	var free *Slot
+	for _, pool := range pools {
+		if candidates := pl.chargerCandidates(vehicle.Type, pl.freeSlots(pool), 1); len(candidates) > 0 {
+			free = pl.allocationStrategy().Select(vehicle.Type, candidates)
+			pl.removeAvailable(free)
+			break
+		}
+	}
+
+	pl.waitlistLock.Lock()
+	defer pl.waitlistLock.Unlock()
+	pl.waitSeq++
+	w := &waiter{
+		id:      pl.id + "_W" + strconv.FormatUint(pl.waitSeq, 10),
+		seq:     pl.waitSeq,
+		vehicle: vehicle,
+		holds:   make(chan WaitlistHold, 1),
+	}
+	pl.waiters[w.id] = w
+	pl.waitlist[vehicle.Type] = append(pl.waitlist[vehicle.Type], w)
+	if free != nil {
+		pl.holdForWaiter(w, free)
+	}
+	return WaitlistEntry{Id: w.id, Vehicle: vehicle, Holds: w.holds}, nil
+}
+
+// WaitlistStatus returns the position of the entry and its hold, if any
+func (pl *ParkingLot) WaitlistStatus(waitID string) (WaitlistStatus, error) {
+	pl.waitlistLock.Lock()
+	defer pl.waitlistLock.Unlock()
+	w, exists := pl.waiters[waitID]
+	if !exists {
+		return WaitlistStatus{}, fmt.Errorf("%w: %s", ErrWaitlistNotFound, waitID)
+	}
+	if w.slot != nil {
+		hold := w.hold
+		return WaitlistStatus{Position: 0, Hold: &hold}, nil
+	}
+	position := 1
+	for _, other := range pl.waitlist[w.vehicle.Type] {
+		if other == w {
+			break
+		}
+		if other.slot == nil {
+			position++
+		}
+	}
+	return WaitlistStatus{Position: position}, nil
+}
+
+// CheckInWaitlist parks the vehicle of the entry on the slot held for it
+func (pl *ParkingLot) CheckInWaitlist(waitID string) (ParkingTicket, error) {
+	pl.lockAll()
+	defer pl.unlockAll()
+
+	pl.waitlistLock.Lock()
+	w, exists := pl.waiters[waitID]
+	if !exists {
+		pl.waitlistLock.Unlock()
+		return ParkingTicket{}, fmt.Errorf("%w: %s", ErrWaitlistNotFound, waitID)
+	}
+	slot := w.slot
+	if slot == nil {
+		pl.waitlistLock.Unlock()
+		return ParkingTicket{}, fmt.Errorf("%w: %s", ErrNoHold, waitID)
+	}
+	pl.removeWaiter(w)
+	pl.waitlistLock.Unlock()
+
+	ticket, err := pl.issueTicket(w.vehicle, []*Slot{slot}, len(pl.freeSlots(slot.Type)), "")
+	if err != nil {
+		// e.g. the vehicle got in meanwhile, the slot goes to the next waiter
+		slot.HeldBy = ""
+		pl.markSlotAvailable(slot)
+		return ParkingTicket{}, err
+	}
+	return ticket, nil
+}
+
+// LeaveWaitlist drops the entry, its held slot passes to the next waiter
+func (pl *ParkingLot) LeaveWaitlist(waitID string) error {
+	pl.lockAll()
+	defer pl.unlockAll()
+
+	pl.waitlistLock.Lock()
+	w, exists := pl.waiters[waitID]
+	if !exists {
+		pl.waitlistLock.Unlock()
+		return fmt.Errorf("%w: %s", ErrWaitlistNotFound, waitID)
+	}
+	slot := w.slot
+	pl.removeWaiter(w)
+	pl.waitlistLock.Unlock()
+
+	if slot != nil {
+		slot.HeldBy = ""
+		pl.markSlotAvailable(slot)
+	}
+	return nil
+}
+
+// offerToWaitlist holds slot for the longest waiting vehicle it suits, false if nobody wants it.
+// Called by markSlotAvailable, the pool lock of slot is held
+func (pl *ParkingLot) offerToWaitlist(slot *Slot) bool {
+	pl.waitlistLock.Lock()
+	defer pl.waitlistLock.Unlock()
+
+	var next *waiter
+	for vtype, queue := range pl.waitlist {
+		if !slices.Contains(pl.compatibility.slotTypes(vtype), slot.Type) || len(pl.chargerCandidates(vtype, []*Slot{slot}, 1)) == 0 {
+			continue
+		}
+		for _, w := range queue {
+			if w.slot != nil {
+				continue
+			}
+			if next == nil || w.seq < next.seq {
+				next = w
+			}
+			break
+		}
+	}
+	if next == nil {
+		return false
+	}
+	pl.holdForWaiter(next, slot)
+	return true
+}
+
+// holdForWaiter keeps slot for w and notifies it, expects the pool lock of slot and the waitlist lock
+func (pl *ParkingLot) holdForWaiter(w *waiter, slot *Slot) {
+	slot.HeldBy = w.id
+	w.slot = slot
+	w.hold = WaitlistHold{
+		WaitId:    w.id,
+		FloorId:   slot.FloorId,
+		SlotId:    slot.Id,
+		ExpiresAt: pl.clock.Now().Add(pl.holdTimeout),
+	}
+	w.stop = pl.clock.AfterFunc(pl.holdTimeout, func() {
+		pl.expireWaitlistHold(w.id, slot)
+	})
+	// only the latest hold matters to the waiter
+	select {
+	case <-w.holds:
+	default:
+	}
+	w.holds <- w.hold
+}
+
+// expireWaitlistHold drops the waiter that did not check in on time, the slot passes to the next one
+func (pl *ParkingLot) expireWaitlistHold(waitID string, slot *Slot) {
+	lock := pl.getLock(slot.GetVehicleType())
+	lock.Lock()
+	defer lock.Unlock()
+
+	pl.waitlistLock.Lock()
+	w, exists := pl.waiters[waitID]
+	if !exists || w.slot != slot {
+		pl.waitlistLock.Unlock()
+		return
+	}
+	pl.removeWaiter(w)
+	pl.waitlistLock.Unlock()
+
+	slot.HeldBy = ""
+	pl.markSlotAvailable(slot)
+}
+
+// dropWaitlistHold takes slot back from the waiter holding it, the waiter keeps its place in the queue.
+// Expects the pool lock of slot
+func (pl *ParkingLot) dropWaitlistHold(slot *Slot) bool {
+	pl.waitlistLock.Lock()
+	defer pl.waitlistLock.Unlock()
+	w, exists := pl.waiters[slot.HeldBy]
+	if !exists || w.slot != slot {
+		return false
+	}
+	if w.stop != nil {
+		w.stop()
+	}
+	w.slot, w.stop = nil, nil
+	slot.HeldBy = ""
+	return true
+}
+
+// isWaitlistHold -> slot is held for a waiter, expects the pool lock of slot
+func (pl *ParkingLot) isWaitlistHold(slot *Slot) bool {
+	pl.waitlistLock.Lock()
+	defer pl.waitlistLock.Unlock()
+	w, exists := pl.waiters[slot.HeldBy]
+	return exists && w.slot == slot
+}
+
+// removeWaiter expects the waitlist lock
+func (pl *ParkingLot) removeWaiter(w *waiter) {
+	if w.stop != nil {
+		w.stop()
+	}
+	delete(pl.waiters, w.id)
+	queue := pl.waitlist[w.vehicle.Type]
+	if i := slices.Index(queue, w); i >= 0 {
+		pl.waitlist[w.vehicle.Type] = slices.Delete(queue, i, i+1)
+	}
+	close(w.holds)
+}
+
+// clearWaitlist drops every waiter, used by Restore as the held slots are replaced
+func (pl *ParkingLot) clearWaitlist() {
+	pl.waitlistLock.Lock()
+	defer pl.waitlistLock.Unlock()
+	for _, w := range pl.waiters {
+		pl.removeWaiter(w)
+	}
+}
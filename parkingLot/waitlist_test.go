@@ -0,0 +1,155 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// fullCarLot is a single Car slot lot, the slot is taken by KA-01-0000
+func fullCarLot(t *testing.T) (*ParkingLot, *FakeClock, ParkingTicket) {
+	t.Helper()
+	clock := NewFakeClock(time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC))
+	lot, err := NewParkingLot(Layout{Floors: []FloorLayout{UniformFloor(Car, 1)}}, &NormalPricing{Clock: clock},
+		WithClock(clock), WithWaitlistHoldTimeout(5*time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ticket, err := lot.CheckIn(Vehicle{RegistrationNumber: "KA-01-0000", Type: Car})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return lot, clock, ticket
+}
+
+func TestWaitlistJoinAndCheckIn(t *testing.T) {
+	lot, clock, parked := fullCarLot(t)
+	first, err := lot.JoinWaitlist(Vehicle{RegistrationNumber: "KA-01-0001", Type: Car})
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := lot.JoinWaitlist(Vehicle{RegistrationNumber: "KA-01-0002", Type: Car})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := lot.JoinWaitlist(Vehicle{RegistrationNumber: "KA-01-0001", Type: Car}); !errors.Is(err, ErrAlreadyWaiting) {
+		t.Fatalf("second join err = %v, want ErrAlreadyWaiting", err)
+	}
+	if _, err := lot.JoinWaitlist(Vehicle{RegistrationNumber: "KA-01-0000", Type: Car}); !errors.Is(err, ErrVehicleAlreadyParked) {
+		t.Fatalf("join of a parked vehicle err = %v, want ErrVehicleAlreadyParked", err)
+	}
+	if _, err := lot.JoinWaitlist(Vehicle{RegistrationNumber: "KA-01-0003", Type: Bus}); !errors.Is(err, ErrCanNotWait) {
+		t.Fatalf("Bus join err = %v, want ErrCanNotWait", err)
+	}
+	if status, _ := lot.WaitlistStatus(second.Id); status.Position != 2 || status.Hold != nil {
+		t.Fatalf("second waiter status %+v, want position 2", status)
+	}
+	if _, err := lot.CheckInWaitlist(first.Id); !errors.Is(err, ErrNoHold) {
+		t.Fatalf("check in without hold err = %v, want ErrNoHold", err)
+	}
+
+	// the freed slot is held for the first waiter, walk ins do not get it
+	if _, err := lot.Unpark(parked.Id); err != nil {
+		t.Fatal(err)
+	}
+	hold := <-first.Holds
+	if hold.WaitId != first.Id || hold.FloorId != 0 || hold.SlotId != 0 || !hold.ExpiresAt.Equal(clock.Now().Add(5*time.Minute)) {
+		t.Fatalf("hold %+v", hold)
+	}
+	if _, err := lot.CheckIn(Vehicle{RegistrationNumber: "KA-01-0009", Type: Car}); !errors.Is(err, ErrNoSlotAvailable) {
+		t.Fatalf("walk in err = %v, want ErrNoSlotAvailable", err)
+	}
+	if status, _ := lot.WaitlistStatus(second.Id); status.Position != 1 {
+		t.Fatalf("second waiter status %+v, want position 1", status)
+	}
+
+	ticket, err := lot.CheckInWaitlist(first.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ticket.VehicleParked.RegistrationNumber != "KA-01-0001" || ticket.SlotDetails.Id != hold.SlotId {
+		t.Fatalf("ticket %+v", ticket)
+	}
+	if _, open := <-first.Holds; open {
+		t.Fatal("holds channel still open after check in")
+	}
+	if _, err := lot.WaitlistStatus(first.Id); !errors.Is(err, ErrWaitlistNotFound) {
+		t.Fatalf("status after check in err = %v, want ErrWaitlistNotFound", err)
+	}
+}
+
+func TestWaitlistHoldTimeout(t *testing.T) {
+	lot, clock, parked := fullCarLot(t)
+	first, _ := lot.JoinWaitlist(Vehicle{RegistrationNumber: "KA-01-0001", Type: Car})
+	second, _ := lot.JoinWaitlist(Vehicle{RegistrationNumber: "KA-01-0002", Type: Car})
+	if _, err := lot.Unpark(parked.Id); err != nil {
+		t.Fatal(err)
+	}
+	<-first.Holds
+
+	// the first waiter does not come, the slot passes to the second one
+	clock.Advance(5 * time.Minute)
+	if _, open := <-first.Holds; open {
+		t.Fatal("first waiter still holds after the timeout")
+	}
+	if _, err := lot.CheckInWaitlist(first.Id); !errors.Is(err, ErrWaitlistNotFound) {
+		t.Fatalf("late check in err = %v, want ErrWaitlistNotFound", err)
+	}
+	hold := <-second.Holds
+	if !hold.ExpiresAt.Equal(clock.Now().Add(5 * time.Minute)) {
+		t.Fatalf("second hold expires at %v, want %v", hold.ExpiresAt, clock.Now().Add(5*time.Minute))
+	}
+
+	// nobody left, the slot is free again
+	clock.Advance(5 * time.Minute)
+	if free := lot.FreeSlotCount(Car); free != 1 {
+		t.Fatalf("%d free slots after both holds expired, want 1", free)
+	}
+}
+
+func TestLeaveWaitlist(t *testing.T) {
+	lot, _, parked := fullCarLot(t)
+	first, _ := lot.JoinWaitlist(Vehicle{RegistrationNumber: "KA-01-0001", Type: Car})
+	second, _ := lot.JoinWaitlist(Vehicle{RegistrationNumber: "KA-01-0002", Type: Car})
+	if _, err := lot.Unpark(parked.Id); err != nil {
+		t.Fatal(err)
+	}
+	<-first.Holds
+
+	// the held slot passes on to the next waiter
+	if err := lot.LeaveWaitlist(first.Id); err != nil {
+		t.Fatal(err)
+	}
+	if err := lot.LeaveWaitlist(first.Id); !errors.Is(err, ErrWaitlistNotFound) {
+		t.Fatalf("second leave err = %v, want ErrWaitlistNotFound", err)
+	}
+	hold := <-second.Holds
+	if status, _ := lot.WaitlistStatus(second.Id); status.Hold == nil || *status.Hold != hold {
+		t.Fatalf("second waiter status %+v, want hold %+v", status, hold)
+	}
+	if err := lot.LeaveWaitlist(second.Id); err != nil {
+		t.Fatal(err)
+	}
+	if free := lot.FreeSlotCount(Car); free != 1 {
+		t.Fatalf("%d free slots once the waitlist is empty, want 1", free)
+	}
+}
+
+func TestJoinWaitlistHoldsAFreeSlot(t *testing.T) {
+	lot, _, parked := fullCarLot(t)
+	if _, err := lot.Unpark(parked.Id); err != nil {
+		t.Fatal(err)
+	}
+	entry, err := lot.JoinWaitlist(Vehicle{RegistrationNumber: "KA-01-0001", Type: Car})
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case hold := <-entry.Holds:
+		if hold.SlotId != 0 {
+			t.Fatalf("hold %+v", hold)
+		}
+	default:
+		t.Fatal("no hold although a slot is free")
+	}
+}